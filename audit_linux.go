@@ -0,0 +1,232 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrDescriptorOpen indicates a file descriptor was open which allows
+// access not allowed by a Locker
+var ErrDescriptorOpen = errors.New("disallowed file descriptor open")
+
+// AuditMode configures what happens to open file descriptors which allow
+// access not allowed by a Locker, see AuditDescriptors.
+type AuditMode int
+
+const (
+	// AuditReport only reports the open file descriptors.
+	AuditReport AuditMode = iota
+
+	// AuditFail causes Lock to fail if a disallowed file descriptor is
+	// open.
+	AuditFail
+
+	// AuditClose closes each disallowed file descriptor, other than stdin,
+	// stdout, and stderr.
+	AuditClose
+)
+
+// A Descriptor is a file descriptor found to be open when a Locker was
+// locked.
+type Descriptor struct {
+	FD      int    // number of the file descriptor
+	Target  string // file the descriptor refers to, as named by /proc/self/fd
+	Allowed bool   // whether the Locker allows the access of the descriptor
+	Closed  bool   // whether the descriptor was closed by AuditClose
+}
+
+func (d Descriptor) String() string {
+	return fmt.Sprintf("%d:%s", d.FD, d.Target)
+}
+
+// audit is the configuration set by AuditDescriptors.
+type audit struct {
+	mode   AuditMode
+	report func([]Descriptor)
+}
+
+// AuditDescriptors creates a Path which configures the Locker to inspect
+// the file descriptors of the process when locking.
+//
+// Landlock does not revoke access through file descriptors opened before
+// Lock, such as those inherited from a parent process. A descriptor is
+// allowed if the Locker allows the access it was opened with (reading a
+// file or directory, or writing a file) to the file it refers to.
+// Descriptors opened with O_PATH, and those which do not refer to a path
+// (e.g. pipes and sockets), are always allowed.
+//
+// If report is not nil, it is called with every open descriptor before the
+// process is restricted. The mode determines what happens to disallowed
+// descriptors. Check reports and fails the same as Lock, but never closes
+// descriptors.
+//
+// Closing a descriptor owned by an os.File causes later uses of the
+// os.File to fail, or to use whichever file later reuses the descriptor.
+func AuditDescriptors(mode AuditMode, report func([]Descriptor)) *Path {
+	return &Path{
+		mode: modeOption,
+		opt: func(l *locker) {
+			l.audit = &audit{mode: mode, report: report}
+		},
+	}
+}
+
+// inspect audits the open file descriptors of the process according to the
+// audit configuration of l, ignoring the ruleset descriptor rs. Disallowed
+// descriptors are closed only if enforce is set.
+func (l *locker) inspect(rs int, enforce bool) error {
+	if l.audit == nil {
+		return nil
+	}
+
+	descriptors, err := l.descriptors(rs)
+	if err != nil {
+		return err
+	}
+
+	var disallowed []string
+	for i, d := range descriptors {
+		if d.Allowed {
+			continue
+		}
+		switch l.audit.mode {
+		case AuditFail:
+			disallowed = append(disallowed, d.String())
+		case AuditClose:
+			if enforce && d.FD > 2 {
+				descriptors[i].Closed = syscall.Close(d.FD) == nil
+			}
+		}
+	}
+
+	if l.audit.report != nil {
+		l.audit.report(descriptors)
+	}
+
+	if len(disallowed) > 0 {
+		return fmt.Errorf("%w: %s", ErrDescriptorOpen, strings.Join(disallowed, ", "))
+	}
+	return nil
+}
+
+// descriptors lists the open file descriptors of the process, other than
+// rs, and whether each is allowed by the paths of l.
+func (l *locker) descriptors(rs int) ([]Descriptor, error) {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = dir.Close() }()
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	// descriptors of FD paths are allowed by definition
+	owned := map[int]bool{rs: true, int(dir.Fd()): true}
+	for _, p := range l.paths.Slice() {
+		if p.file != nil {
+			owned[int(p.file.Fd())] = true
+		}
+	}
+
+	var result []Descriptor
+	for _, name := range names {
+		fd, err := strconv.Atoi(name)
+		if err != nil || owned[fd] {
+			continue
+		}
+		target, err := os.Readlink("/proc/self/fd/" + name)
+		if err != nil {
+			continue // closed in the meantime
+		}
+		result = append(result, Descriptor{
+			FD:      fd,
+			Target:  target,
+			Allowed: l.allows(fd, target),
+		})
+	}
+	return result, nil
+}
+
+// allows returns whether the paths of l allow the access of fd, which
+// refers to target.
+func (l *locker) allows(fd int, target string) bool {
+	if !strings.HasPrefix(target, "/") {
+		// pipes, sockets, anonymous inodes, etc.
+		return true
+	}
+
+	need := required(fd)
+	if need == 0 {
+		return true
+	}
+
+	q := &Path{path: target}
+	allow := rule(0)
+	for _, p := range l.paths.Slice() {
+		if covers(p, q) {
+			allow |= p.access()
+		}
+	}
+	return need&^allow == 0
+}
+
+// required returns the access granted by fd, according to the flags it was
+// opened with.
+func required(fd int) rule {
+	flags, err := fdFlags(fd)
+	if err != nil || flags&unix.O_PATH != 0 {
+		return 0
+	}
+
+	_, dir, err := identify(fd)
+	if err != nil {
+		return 0
+	}
+
+	var need rule
+	mode := flags & unix.O_ACCMODE
+	if mode == unix.O_RDONLY || mode == unix.O_RDWR {
+		need |= ifelse(dir, fsReadDir, fsReadFile)
+	}
+	if mode == unix.O_WRONLY || mode == unix.O_RDWR {
+		need |= fsWriteFile
+	}
+	return need
+}
+
+// fdFlags returns the flags fd was opened with, as reported by
+// /proc/self/fdinfo.
+func fdFlags(fd int) (int, error) {
+	f, err := os.Open("/proc/self/fdinfo/" + strconv.Itoa(fd))
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "flags:"); ok {
+			flags, err := strconv.ParseInt(strings.TrimSpace(value), 8, 64)
+			return int(flags), err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no flags for descriptor %d", fd)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test/must"
+	"golang.org/x/sys/unix"
+)
+
+func TestLocker_AuditDescriptors(t *testing.T) {
+	// find returns the descriptor of f in the report
+	find := func(report []Descriptor, f *os.File) Descriptor {
+		for _, d := range report {
+			if d.FD == int(f.Fd()) {
+				return d
+			}
+		}
+		t.Fatalf("descriptor of %s not reported", f.Name())
+		return Descriptor{}
+	}
+
+	open := func(name string, flag int) *os.File {
+		f, err := os.OpenFile(name, flag, 0)
+		must.NoError(t, err)
+		return f
+	}
+
+	cases := map[string]func(){
+		"report": func() {
+			corn := open("tests/veggies/corn.txt", os.O_RDONLY)
+			apple := open("tests/fruits/apple.txt", os.O_RDONLY)
+
+			var report []Descriptor
+			err := New(
+				Shared(),
+				Dir("tests/fruits", "r"),
+				AuditDescriptors(AuditReport, func(d []Descriptor) { report = d }),
+			).Lock(Mandatory)
+			must.NoError(t, err)
+
+			d := find(report, corn)
+			must.False(t, d.Allowed)
+			must.False(t, d.Closed)
+			must.StrHasSuffix(t, "tests/veggies/corn.txt", d.Target)
+			must.True(t, find(report, apple).Allowed)
+
+			// landlock does not revoke the leaked descriptor
+			b := make([]byte, 4)
+			_, err = corn.Read(b)
+			must.NoError(t, err)
+		},
+		"fail": func() {
+			corn := open("tests/veggies/corn.txt", os.O_RDONLY)
+
+			err := New(
+				Shared(),
+				Dir("tests/fruits", "r"),
+				AuditDescriptors(AuditFail, nil),
+			).Lock(Mandatory)
+			must.ErrorIs(t, err, ErrDescriptorOpen)
+			must.ErrorContains(t, err, "corn.txt")
+			must.Zero(t, Layers())
+
+			// the process is not restricted
+			_, err = os.ReadFile("tests/veggies/corn.txt")
+			must.NoError(t, err)
+			must.Close(t, corn)
+		},
+		"close": func() {
+			corn := open("tests/veggies/corn.txt", os.O_RDONLY)
+			veggies := open("tests/veggies", os.O_RDONLY)
+			apple := open("tests/fruits/apple.txt", os.O_WRONLY)
+			pinned := open("tests/veggies/corn.txt", unix.O_PATH)
+
+			var report []Descriptor
+			err := New(
+				Shared(),
+				Dir("tests/fruits", "r"),
+				AuditDescriptors(AuditClose, func(d []Descriptor) { report = d }),
+			).Lock(Mandatory)
+			must.NoError(t, err)
+
+			must.True(t, find(report, corn).Closed)
+			must.True(t, find(report, veggies).Closed)
+			must.True(t, find(report, apple).Closed) // not writable
+			must.True(t, find(report, pinned).Allowed)
+			must.False(t, find(report, pinned).Closed)
+
+			b := make([]byte, 4)
+			_, err = corn.Read(b)
+			must.Error(t, err)
+		},
+		"check": func() {
+			corn := open("tests/veggies/corn.txt", os.O_RDONLY)
+
+			var report []Descriptor
+			l := New(
+				Shared(),
+				Dir("tests", "r"),
+				AuditDescriptors(AuditClose, func(d []Descriptor) { report = d }),
+			)
+			must.NoError(t, l.Check(Mandatory))
+			must.True(t, find(report, corn).Allowed)
+
+			l = New(
+				Shared(),
+				Dir("tests/fruits", "r"),
+				AuditDescriptors(AuditClose, func(d []Descriptor) { report = d }),
+			)
+			must.NoError(t, l.Check(Mandatory))
+			must.False(t, find(report, corn).Allowed)
+			must.False(t, find(report, corn).Closed)
+
+			l = New(Shared(), Dir("tests/fruits", "r"), AuditDescriptors(AuditFail, nil))
+			must.ErrorIs(t, l.Check(Mandatory), ErrDescriptorOpen)
+			must.Close(t, corn)
+		},
+		"fd_path": func() {
+			dir := open("tests/veggies", unix.O_RDONLY|unix.O_DIRECTORY)
+			abs, err := filepath.Abs("tests/veggies")
+			must.NoError(t, err)
+
+			var report []Descriptor
+			err = New(
+				Shared(),
+				FDDir(dir, "r"),
+				AuditDescriptors(AuditClose, func(d []Descriptor) { report = d }),
+			).Lock(Mandatory)
+			must.NoError(t, err)
+
+			// the descriptor of an FD path is part of the policy
+			for _, d := range report {
+				must.NotEq(t, int(dir.Fd()), d.FD)
+			}
+			_, err = os.ReadFile(filepath.Join(abs, "corn.txt"))
+			must.NoError(t, err)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_AuditDescriptors", cases)
+}
@@ -14,3 +14,8 @@ func Detect() (int, error) {
 func Available() bool {
 	return false
 }
+
+// ThreadSync returns false on non-Linux platforms.
+func ThreadSync() bool {
+	return false
+}
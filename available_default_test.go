@@ -21,3 +21,7 @@ func Test_Detect(t *testing.T) {
 	must.Error(t, err)
 	must.Zero(t, v)
 }
+
+func Test_ThreadSync(t *testing.T) {
+	must.False(t, ThreadSync())
+}
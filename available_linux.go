@@ -5,9 +5,14 @@
 
 package landlock
 
+import (
+	"sync/atomic"
+)
+
 var (
 	available bool
 	version   int
+	tsync     atomic.Bool
 )
 
 func init() {
@@ -15,6 +20,7 @@ func init() {
 	if err == nil {
 		available = true
 		version = v
+		tsync.Store(v >= 8)
 	}
 }
 
@@ -27,3 +33,10 @@ func Detect() (int, error) {
 func Available() bool {
 	return available
 }
+
+// ThreadSync returns true if Lock restricts all threads of the process using
+// the thread synchronising flag of landlock_restrict_self, which is available
+// since landlock ABI version 8. Otherwise, psx is used to restrict each thread.
+func ThreadSync() bool {
+	return tsync.Load()
+}
@@ -21,3 +21,7 @@ func Test_Detect(t *testing.T) {
 	must.NoError(t, err)
 	must.Positive(t, v)
 }
+
+func Test_ThreadSync(t *testing.T) {
+	must.Eq(t, version >= 8, ThreadSync())
+}
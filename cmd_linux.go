@@ -0,0 +1,243 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// trampolineExec is the marker argument indicating the process was started
+// by Cmd to restrict itself before executing the actual command.
+const trampolineExec = "-landlock-trampoline-exec"
+
+func init() {
+	if len(os.Args) > 1 && os.Args[1] == trampolineExec {
+		trampoline(os.Args[2:])
+	}
+}
+
+// Cmd is an *exec.Cmd whose child process is restricted by a Locker,
+// leaving the parent process unrestricted.
+//
+// The child process is a re-execution of the current binary, which
+// restricts itself before executing the actual command. This happens
+// during initialization of this package, before main is run.
+type Cmd struct {
+	*exec.Cmd
+
+	// Safety is the enforcement behavior used when restricting the
+	// child process. The default is Mandatory.
+	Safety Safety
+
+	locker   *locker
+	compiled *Compiled
+}
+
+// Command returns a Cmd to execute the named program with the given
+// arguments, where the child process is restricted by l.
+//
+// As with exec.Command, if name contains no path separators it is resolved
+// using the PATH of the parent process. The resolved program must be
+// executable under l, along with any files it needs to run (e.g. Shared).
+func Command(l Locker, name string, arg ...string) *Cmd {
+	return CommandContext(context.Background(), l, name, arg...)
+}
+
+// CommandContext is like Command but includes a context, which is used to
+// kill the child process as with exec.CommandContext.
+//
+// If l was not created by New, Start returns ErrForeignLocker.
+func CommandContext(ctx context.Context, l Locker, name string, arg ...string) *Cmd {
+	c := &Cmd{Cmd: exec.CommandContext(ctx, name, arg...)}
+	lk, ok := l.(*locker)
+	if !ok {
+		c.Err = fmt.Errorf("%w: %T", ErrForeignLocker, l)
+		return c
+	}
+	c.locker = lk
+	return c
+}
+
+// Start starts the command, but does not wait for it to complete.
+//
+// An error restricting the child process or executing the command is
+// returned by Start, in which case the child process has already exited.
+func (c *Cmd) Start() error {
+	if c.Err != nil {
+		return c.Err
+	}
+
+	if c.compiled != nil {
+		return c.compiled.start(c)
+	}
+
+	if !available {
+		if c.Safety == Try || c.Safety == OnlyAvailable {
+			return c.Cmd.Start()
+		}
+		return ErrLandlockNotAvailable
+	}
+
+	rs, err := c.locker.ruleset()
+	if err != nil {
+		if c.Safety == Try {
+			return c.Cmd.Start()
+		}
+		return errors.Join(ErrLandlockFailedToLock, err)
+	}
+	defer func() { _ = rs.Close() }()
+
+	return c.start(rs, trampolineExec, nil, append([]string{c.Path}, c.Args...)...)
+}
+
+// start runs the current binary as a trampoline of the given kind, which
+// restricts itself using the ruleset of rs before continuing. The extra
+// files are passed to the trampoline following the ruleset and status
+// pipe, and args are appended to the arguments of the trampoline.
+func (c *Cmd) start(rs *os.File, kind string, extra []*os.File, args ...string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	path, argv, files := c.Path, c.Args, c.ExtraFiles
+	n := 3 + len(files)
+	c.Path = self
+	c.Args = append([]string{
+		argv[0],
+		kind,
+		strconv.Itoa(int(c.Safety)),
+		strconv.Itoa(n),     // ruleset
+		strconv.Itoa(n + 1), // status
+	}, args...)
+	c.ExtraFiles = append(files[:len(files):len(files)], rs, w)
+	c.ExtraFiles = append(c.ExtraFiles, extra...)
+
+	err = c.Cmd.Start()
+	c.Path, c.Args, c.ExtraFiles = path, argv, files
+	_ = w.Close()
+	if err != nil {
+		return err
+	}
+
+	// the status pipe is closed on a successful exec, otherwise the
+	// trampoline writes the reason for failure before exiting
+	var status bytes.Buffer
+	_, _ = status.ReadFrom(r)
+	if status.Len() == 0 {
+		return nil
+	}
+	_ = c.Wait()
+
+	var (
+		step  string
+		errno syscall.Errno
+	)
+	if _, err = fmt.Sscan(status.String(), &step, &errno); err != nil {
+		return fmt.Errorf("landlock trampoline: %s", status.String())
+	}
+	if step == "lock" {
+		return errors.Join(ErrLandlockFailedToLock, errno)
+	}
+	return &os.PathError{Op: "exec", Path: path, Err: errno}
+}
+
+// Run starts the command and waits for it to complete.
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output runs the command and returns its standard output.
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	err := c.Run()
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its combined standard
+// output and standard error.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	var b bytes.Buffer
+	c.Stdout = &b
+	c.Stderr = &b
+	err := c.Run()
+	return b.Bytes(), err
+}
+
+// trampoline runs in the child process started by Cmd; it restricts the
+// process using the inherited ruleset and then executes the command.
+func trampoline(args []string) {
+	// the executed command inherits the restrictions of the thread calling
+	// execve, so there is no need to restrict every thread of the process,
+	// nor to modify the ruleset which may be shared with other children
+	runtime.LockOSThread()
+	status, args := restrictTrampoline(args, enforceThread)
+	path, argv := args[0], args[1:]
+
+	err := syscall.Exec(path, argv, os.Environ())
+	fail(status, "exec", err)
+}
+
+// restrictTrampoline restricts the trampoline process using the inherited
+// ruleset and the given enforce function, returning the status pipe and the
+// remaining arguments.
+func restrictTrampoline(args []string, enforce func(int) error) (*os.File, []string) {
+	safety, _ := strconv.Atoi(args[0])
+	rs, _ := strconv.Atoi(args[1])
+	fd, _ := strconv.Atoi(args[2])
+	status := os.NewFile(uintptr(fd), "landlock-status")
+
+	// neither descriptor should be inherited by an executed command
+	unix.CloseOnExec(rs)
+	unix.CloseOnExec(fd)
+
+	if err := enforce(rs); err != nil && Safety(safety) != Try {
+		fail(status, "lock", err)
+	}
+	_ = syscall.Close(rs)
+
+	return status, args[3:]
+}
+
+// fail reports the failed step of the trampoline and its errno, similar to
+// how the runtime reports a failed exec in between fork and exec, and exits.
+func fail(status *os.File, step string, err error) {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		errno = syscall.EINVAL
+	}
+	_, _ = fmt.Fprintf(status, "%s %d", step, errno)
+	os.Exit(1)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestCmd_allowed(t *testing.T) {
+	l := New(
+		Shared(),
+		File("/usr/bin/cat", "rx"),
+		File("tests/fruits/apple.txt", "r"),
+	)
+	cmd := CommandContext(t.Context(), l, "/usr/bin/cat", "tests/fruits/apple.txt")
+	b, err := cmd.Output()
+	must.NoError(t, err)
+	must.Eq(t, "apple\n\n", string(b))
+
+	// the parent process is not restricted
+	_, err = os.ReadFile("tests/veggies/corn.txt")
+	must.NoError(t, err)
+}
+
+func TestCmd_denied(t *testing.T) {
+	l := New(
+		Shared(),
+		File("/usr/bin/cat", "rx"),
+		File("tests/fruits/apple.txt", "r"),
+	)
+	cmd := CommandContext(t.Context(), l, "/usr/bin/cat", "tests/veggies/corn.txt")
+	b, err := cmd.CombinedOutput()
+	must.Error(t, err) // exit status
+	must.StrContains(t, string(b), "Permission denied")
+}
+
+func TestCmd_execDenied(t *testing.T) {
+	l := New(Shared())
+	cmd := CommandContext(t.Context(), l, "/usr/bin/cat", "tests/fruits/apple.txt")
+	err := cmd.Start()
+	must.ErrorIs(t, err, os.ErrPermission)
+	must.ErrorContains(t, err, "exec /usr/bin/cat")
+}
+
+func TestCmd_lockFailure(t *testing.T) {
+	l := New(
+		Shared(),
+		File("/usr/bin/cat", "rx"),
+		File("tests/fruits/missing.txt", "r"),
+	)
+	cmd := CommandContext(t.Context(), l, "/usr/bin/cat", "tests/fruits/apple.txt")
+	err := cmd.Run()
+	must.ErrorIs(t, err, ErrLandlockFailedToLock)
+}
+
+func TestCmd_notFound(t *testing.T) {
+	l := New(Shared())
+	cmd := CommandContext(t.Context(), l, "no-such-program")
+	err := cmd.Run()
+	must.Error(t, err)
+}
+
+// foreignLocker is a Locker implemented outside of the package.
+type foreignLocker struct {
+	Locker
+}
+
+func TestCmd_foreignLocker(t *testing.T) {
+	cmd := CommandContext(t.Context(), foreignLocker{New()}, "/usr/bin/true")
+	err := cmd.Run()
+	must.ErrorIs(t, err, ErrForeignLocker)
+}
+
+func TestCmd_extraFiles(t *testing.T) {
+	f, err := os.Open("tests/fruits/banana.txt")
+	must.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	// the descriptor is inherited as fd 3, and not affected by the ruleset
+	l := New(
+		Shared(),
+		File("/usr/bin/bash", "rx"),
+		File("/usr/bin/cat", "rx"),
+	)
+	cmd := CommandContext(t.Context(), l, "/usr/bin/bash", "-c", "/usr/bin/cat <&3")
+	cmd.ExtraFiles = []*os.File{f}
+	b, err := cmd.Output()
+	must.NoError(t, err)
+	must.Eq(t, "banana\n", string(b))
+	must.SliceLen(t, 1, cmd.ExtraFiles)
+}
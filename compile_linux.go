@@ -0,0 +1,81 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// ErrCompiledClosed indicates a Compiled was used after being closed
+var ErrCompiledClosed = errors.New("compiled ruleset closed")
+
+// Compiled is the ruleset of a Locker, built once and used to restrict any
+// number of child processes.
+//
+// Compared with Command, the paths of the Locker are not opened again and
+// the ruleset is not rebuilt for every child process, which makes Compiled
+// suitable for launching many children with the same policy. The paths are
+// resolved at the time of Compile. A Compiled is safe for concurrent use.
+type Compiled struct {
+	lock sync.RWMutex
+	file *os.File
+}
+
+// Compile builds the ruleset of l, for use with many child processes.
+//
+// The Compiled must be closed when no longer needed.
+func Compile(l Locker) (*Compiled, error) {
+	f, err := Ruleset(l)
+	if err != nil {
+		return nil, err
+	}
+	return &Compiled{file: f}, nil
+}
+
+// Command returns a Cmd to execute the named program with the given
+// arguments, where the child process is restricted by the ruleset of r.
+//
+// See Command for details.
+func (r *Compiled) Command(name string, arg ...string) *Cmd {
+	return r.CommandContext(context.Background(), name, arg...)
+}
+
+// CommandContext is like Command but includes a context, which is used to
+// kill the child process as with exec.CommandContext.
+func (r *Compiled) CommandContext(ctx context.Context, name string, arg ...string) *Cmd {
+	return &Cmd{
+		Cmd:      exec.CommandContext(ctx, name, arg...),
+		compiled: r,
+	}
+}
+
+// Close closes the ruleset of r. Child processes already started remain
+// restricted.
+func (r *Compiled) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *Compiled) start(c *Cmd) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.file == nil {
+		return ErrCompiledClosed
+	}
+	return c.start(r.file, trampolineExec, nil, append([]string{c.Path}, c.Args...)...)
+}
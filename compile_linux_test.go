@@ -0,0 +1,110 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestCompiled_Command(t *testing.T) {
+	r, err := Compile(New(
+		Shared(),
+		File("/usr/bin/cat", "rx"),
+		File("tests/fruits/apple.txt", "r"),
+	))
+	must.NoError(t, err)
+	defer func() { must.NoError(t, r.Close()) }()
+
+	type result struct {
+		output  []byte
+		allowed error
+		denied  error
+	}
+
+	var wg sync.WaitGroup
+	results := make([]result, 8)
+	for i := range results {
+		wg.Go(func() {
+			cmd := r.CommandContext(t.Context(), "/usr/bin/cat", "tests/fruits/apple.txt")
+			results[i].output, results[i].allowed = cmd.Output()
+
+			cmd = r.CommandContext(t.Context(), "/usr/bin/cat", "tests/fruits/banana.txt")
+			results[i].denied = cmd.Run()
+		})
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		must.NoError(t, result.allowed)
+		must.Eq(t, "apple\n\n", string(result.output))
+		must.Error(t, result.denied)
+	}
+
+	// the parent process is not restricted
+	_, err = os.ReadFile("tests/fruits/banana.txt")
+	must.NoError(t, err)
+}
+
+func TestCompiled_error(t *testing.T) {
+	_, err := Compile(New(File("tests/fruits/missing.txt", "r")))
+	must.ErrorIs(t, err, ErrLandlockFailedToLock)
+}
+
+func TestCompiled_Close(t *testing.T) {
+	r, err := Compile(New(Shared(), File("/usr/bin/true", "rx")))
+	must.NoError(t, err)
+	must.NoError(t, r.Close())
+	must.NoError(t, r.Close())
+
+	err = r.CommandContext(t.Context(), "/usr/bin/true").Run()
+	must.ErrorIs(t, err, ErrCompiledClosed)
+}
+
+// Compare the per-spawn cost of a compiled ruleset against building the
+// ruleset from a Locker for every child process.
+
+func benchPaths() []*Path {
+	return []*Path{
+		Shared(),
+		Stdio(),
+		Certs(),
+		File("/usr/bin/true", "rx"),
+	}
+}
+
+func BenchmarkLocker_Command(b *testing.B) {
+	paths := benchPaths()
+	for b.Loop() {
+		err := Command(New(paths...), "/usr/bin/true").Run()
+		must.NoError(b, err)
+	}
+}
+
+func BenchmarkCompiled_Command(b *testing.B) {
+	r, err := Compile(New(benchPaths()...))
+	must.NoError(b, err)
+	defer func() { _ = r.Close() }()
+
+	for b.Loop() {
+		err = r.Command("/usr/bin/true").Run()
+		must.NoError(b, err)
+	}
+}
+
+// The portion of the per-spawn cost saved by a compiled ruleset.
+
+func BenchmarkLocker_Ruleset(b *testing.B) {
+	paths := benchPaths()
+	for b.Loop() {
+		f, err := Ruleset(New(paths...))
+		must.NoError(b, err)
+		_ = f.Close()
+	}
+}
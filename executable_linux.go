@@ -0,0 +1,244 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrLibraryNotFound indicates a shared library needed by an
+	// executable could not be found
+	ErrLibraryNotFound = errors.New("shared library not found")
+
+	// ErrNotExecutable indicates a file is not an ELF executable or
+	// shared object
+	ErrNotExecutable = errors.New("not an ELF executable")
+)
+
+// Executable creates a Path representing the ELF executable at path, along
+// with its dynamic loader and every shared library it transitively depends
+// on, each allowed "rx".
+//
+// Shared libraries are located the way the dynamic loader does, searching
+// DT_RPATH, LD_LIBRARY_PATH, DT_RUNPATH, /etc/ld.so.cache, and then the
+// default library directories. Symbolic links are resolved, so the rules
+// apply to the files themselves. Unlike Shared, only the needed files are
+// allowed, rather than entire library directories.
+//
+// The dependencies are resolved when the Locker is created; any error in
+// doing so is returned by Lock. Libraries loaded at runtime with dlopen
+// are not included.
+func Executable(path string) *Path {
+	if !IsProperPath(path) {
+		panic("improper path")
+	}
+	return &Path{
+		mode: modeDerived,
+		path: path,
+		expand: func(l *locker) ([]*Path, error) {
+			return executable(absolute(l.base, path))
+		},
+	}
+}
+
+// executable resolves the paths needed to execute the ELF file at path.
+func executable(path string) ([]*Path, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrNotExecutable, path, err)
+	}
+	r := &resolver{
+		class:   f.Class,
+		machine: f.Machine,
+		seen:    make(map[string]bool),
+	}
+	_ = f.Close()
+
+	if err = r.object(path, nil); err != nil {
+		return nil, err
+	}
+	return r.paths, nil
+}
+
+// resolver finds the dependencies of an executable, in the manner of the
+// dynamic loader.
+type resolver struct {
+	class   elf.Class
+	machine elf.Machine
+
+	cache  ldCache
+	cached bool // whether loading the cache has been attempted
+
+	seen  map[string]bool // real paths already included
+	paths []*Path
+}
+
+// object includes the ELF file at path and its dependencies, where rpath
+// is the DT_RPATH of the objects which caused it to be loaded.
+func (r *resolver) object(path string, rpath []string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if r.seen[real] {
+		return nil
+	}
+	r.seen[real] = true
+
+	f, err := elf.Open(real)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNotExecutable, real, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	r.paths = append(r.paths, File(real, "rx"))
+
+	if interp, ok := interpreter(f); ok {
+		if err = r.object(interp, nil); err != nil {
+			return err
+		}
+	}
+
+	origin := filepath.Dir(real)
+	needed, _ := f.DynString(elf.DT_NEEDED)
+	runpath, _ := f.DynString(elf.DT_RUNPATH)
+	own, _ := f.DynString(elf.DT_RPATH)
+
+	// DT_RPATH applies to objects loaded on behalf of this one, unless
+	// DT_RUNPATH is present, which takes precedence and is not inherited
+	var dirs []string
+	if len(runpath) == 0 {
+		rpath = append(r.expand(own, origin), rpath...)
+		dirs = append(dirs, rpath...)
+	}
+	dirs = append(dirs, r.expand(filepath.SplitList(os.Getenv("LD_LIBRARY_PATH")), origin)...)
+	dirs = append(dirs, r.expand(runpath, origin)...)
+
+	for _, name := range needed {
+		found, err := r.find(name, dirs)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("%w: %s needed by %s", ErrLibraryNotFound, name, real)
+		}
+		for _, lib := range found {
+			if err = r.object(lib, rpath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// find returns the paths of the shared library name, searching dirs, then
+// the cache, then the default directories. Every compatible entry of the
+// cache is returned, as the loader may choose between them by hardware
+// capability.
+func (r *resolver) find(name string, dirs []string) ([]string, error) {
+	if strings.Contains(name, "/") {
+		return []string{name}, nil
+	}
+
+	for _, dir := range dirs {
+		if candidate := filepath.Join(dir, name); r.compatible(candidate) {
+			return []string{candidate}, nil
+		}
+	}
+
+	if !r.cached {
+		r.cached = true
+		cache, err := readLDCache(ldCachePath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			r.cache = cache
+			r.paths = append(r.paths, File(ldCachePath, "r"))
+		}
+	}
+
+	var found []string
+	for _, candidate := range r.cache[name] {
+		if r.compatible(candidate) {
+			found = append(found, candidate)
+		}
+	}
+	if len(found) > 0 {
+		return found, nil
+	}
+
+	for _, dir := range r.defaults() {
+		if candidate := filepath.Join(dir, name); r.compatible(candidate) {
+			return []string{candidate}, nil
+		}
+	}
+	return nil, nil
+}
+
+// compatible returns whether path is an ELF file of the same class and
+// machine as the executable.
+func (r *resolver) compatible(path string) bool {
+	f, err := elf.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	return f.Class == r.class && f.Machine == r.machine
+}
+
+// defaults returns the directories searched by the loader after the cache.
+func (r *resolver) defaults() []string {
+	if r.class == elf.ELFCLASS64 {
+		return []string{"/lib64", "/usr/lib64", "/lib", "/usr/lib"}
+	}
+	return []string{"/lib", "/usr/lib", "/lib32", "/usr/lib32"}
+}
+
+// expand splits the colon separated search paths of list and substitutes
+// the dynamic string tokens, dropping directories using tokens which
+// cannot be substituted.
+func (r *resolver) expand(list []string, origin string) []string {
+	lib := ifelse(r.class == elf.ELFCLASS64, "lib64", "lib")
+	replacer := strings.NewReplacer(
+		"${ORIGIN}", origin,
+		"$ORIGIN", origin,
+		"${LIB}", lib,
+		"$LIB", lib,
+	)
+
+	var dirs []string
+	for _, entry := range list {
+		for _, dir := range strings.Split(entry, ":") {
+			if dir = replacer.Replace(dir); dir != "" && !strings.Contains(dir, "$") {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// interpreter returns the dynamic loader requested by f, if any.
+func interpreter(f *elf.File) (string, bool) {
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		b := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(b, 0); err != nil {
+			return "", false
+		}
+		return strings.TrimRight(string(b), "\x00"), true
+	}
+	return "", false
+}
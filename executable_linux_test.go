@@ -0,0 +1,88 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestExecutable(t *testing.T) {
+	t.Run("dynamic", func(t *testing.T) {
+		paths, err := executable("/usr/bin/cat")
+		must.NoError(t, err)
+
+		var names []string
+		for _, p := range paths {
+			must.Eq(t, "rx", ifelse(p.path == ldCachePath, "rx", p.mode))
+			must.False(t, p.dir)
+			names = append(names, filepath.Base(p.path))
+		}
+		must.SliceContains(t, names, "cat")
+		must.SliceContains(t, names, "libc.so.6")
+		must.True(t, strings.HasPrefix(names[1], "ld-"))
+	})
+
+	t.Run("not_elf", func(t *testing.T) {
+		_, err := executable("tests/fruits/apple.txt")
+		must.ErrorIs(t, err, ErrNotExecutable)
+	})
+
+	t.Run("deferred", func(t *testing.T) {
+		l := New(Executable("tests/fruits/apple.txt"))
+		err := l.Check(Mandatory)
+		must.ErrorIs(t, err, ErrNotExecutable)
+		must.ErrorIs(t, err, ErrLandlockFailedToLock)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		l := New(Executable("/does/not/exist"))
+		must.Error(t, l.Check(Mandatory))
+	})
+}
+
+func Test_resolver_expand(t *testing.T) {
+	r := &resolver{class: elf.ELFCLASS64}
+	dirs := r.expand([]string{"$ORIGIN/../lib:/opt/${LIB}", "/$PLATFORM/x", ""}, "/srv/app/bin")
+	must.Eq(t, []string{"/srv/app/bin/../lib", "/opt/lib64"}, dirs)
+}
+
+func TestLocker_Executable(t *testing.T) {
+	cases := map[string]func(){
+		"exec": func() {
+			err := New(
+				Executable("/usr/bin/cat"),
+				File("/dev/null", "rw"), // stdin of exec.Cmd
+				File("tests/fruits/apple.txt", "r"),
+			).Lock(Mandatory)
+			must.NoError(t, err)
+
+			cmd := exec.CommandContext(t.Context(), "/usr/bin/cat", "tests/fruits/apple.txt")
+			b, err := cmd.Output()
+			must.NoError(t, err)
+			must.Eq(t, "apple\n\n", string(b))
+
+			// other executables and libraries are not allowed
+			cmd = exec.CommandContext(t.Context(), "/usr/bin/ls")
+			err = cmd.Run()
+			must.ErrorIs(t, err, os.ErrPermission)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_Executable", cases)
+}
@@ -0,0 +1,816 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// groups are the paths of the built-in groups, e.g. Shared, as evaluated
+// for the distribution of a root directory. Each group is evaluated the
+// first time it is used.
+type groups struct {
+	root         string
+	distribution distribution
+
+	lock  sync.Mutex
+	paths map[string][]*Path // of each group evaluated so far
+}
+
+// ErrGroupNotFound indicates a group name which refers to neither a
+// built-in nor a registered group
+var ErrGroupNotFound = errors.New("group not found")
+
+var (
+	registeredLock sync.RWMutex
+	registered     = make(map[string][]*Path)
+)
+
+// builtinGroups are the names of the built-in groups.
+var builtinGroups = []string{"shared", "stdio", "tty", "tmp", "vminfo", "dns", "certs", "nss"}
+
+// RegisterGroup makes the given paths available as a group under name, to
+// be referred to by Group or ParsePath (e.g. "g::jvm") when creating a
+// Locker. The paths may include other groups, built-in or registered, and
+// paths such as Executable. Relative paths are resolved by each Locker the
+// group is used with.
+//
+// RegisterGroup panics if name is improper, is the name of a built-in
+// group or an already registered group, or if paths include an option or
+// a group not yet registered.
+func RegisterGroup(name string, paths ...*Path) {
+	if !IsProperGroup(name) {
+		panic("landlock: improper group name: " + name)
+	}
+	if slices.Contains(builtinGroups, name) {
+		panic("landlock: group is built-in: " + name)
+	}
+
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+
+	if _, exists := registered[name]; exists {
+		panic("landlock: group already registered: " + name)
+	}
+	for _, p := range paths {
+		switch {
+		case p.mode == modeOption:
+			panic("landlock: option in group: " + name)
+		case p.mode != modeGroup:
+			continue
+		case slices.Contains(builtinGroups, p.path):
+			continue
+		}
+		if _, exists := registered[p.path]; !exists {
+			panic("landlock: group " + name + " refers to unregistered group: " + p.path)
+		}
+	}
+	registered[name] = slices.Clone(paths)
+}
+
+func registeredGroup(name string) ([]*Path, bool) {
+	registeredLock.RLock()
+	defer registeredLock.RUnlock()
+	paths, exists := registered[name]
+	return paths, exists
+}
+
+var (
+	groupsLock sync.Mutex
+	evaluated  = make(map[string]*groups) // by root directory
+	override   string                     // distribution set by SetDistribution
+)
+
+// named returns the paths of the built-in group of the given name,
+// evaluating the group on first use.
+func (g *groups) named(name string) ([]*Path, bool) {
+	if !slices.Contains(builtinGroups, name) {
+		return nil, false
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	paths, exists := g.paths[name]
+	if !exists {
+		paths = g.evaluate(name)
+		for _, p := range paths {
+			p.builtin = true
+		}
+		g.paths[name] = paths
+	}
+	return paths, true
+}
+
+// groupsOf returns the built-in groups of root, caching the groups and
+// their contents once evaluated until RefreshGroups is called.
+func groupsOf(root string) *groups {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+
+	if g, exists := evaluated[root]; exists {
+		return g
+	}
+
+	d := detect(root)
+	if override != "" {
+		d = d.as(override)
+	}
+	g := newGroups(root, d)
+	evaluated[root] = g
+	return g
+}
+
+// RefreshGroups discards the cached contents of the built-in groups (e.g.
+// Shared, DNS), which are evaluated the first time a Locker using them is
+// created. The groups are evaluated again when the next such Locker is
+// created, picking up files created or removed since.
+//
+// Lockers created before calling RefreshGroups are not affected.
+func RefreshGroups() {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+	clear(evaluated)
+}
+
+// GroupRoot creates a Path which configures the Locker to evaluate the
+// built-in groups against the root directory dir, rather than "/". The
+// paths of the groups are prefixed with dir. This is mostly useful for
+// testing.
+func GroupRoot(dir string) *Path {
+	return &Path{
+		mode: modeOption,
+		opt: func(l *locker) {
+			l.root = absolute("", dir)
+		},
+	}
+}
+
+// Distribution returns the ID of the Linux distribution of the host (e.g.
+// "debian", "nixos", "alpine") as given by /etc/os-release, or as set by
+// SetDistribution. The contents of the built-in groups are adapted to the
+// layout of the distribution.
+func Distribution() string {
+	return groupsOf("/").distribution.id
+}
+
+// SetDistribution overrides the detected Linux distribution of the host,
+// adapting the built-in groups to the layout of the distribution with the
+// given ID, as it would appear in /etc/os-release. An empty ID restores the
+// detected distribution.
+//
+// Lockers created before calling SetDistribution are not affected.
+func SetDistribution(id string) {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+	override = id
+	clear(evaluated)
+}
+
+// distribution describes the layout of the Linux distribution of a root
+// directory.
+type distribution struct {
+	id   string   // ID of os-release
+	like []string // ID_LIKE of os-release
+	nix  bool     // whether the nix store exists
+	guix bool     // whether the guix store exists
+}
+
+// is returns whether d is, or is derived from, the distribution id.
+func (d distribution) is(id string) bool {
+	return d.id == id || slices.Contains(d.like, id)
+}
+
+// as returns d identifying as the distribution id, keeping the detected
+// store layouts.
+func (d distribution) as(id string) distribution {
+	return distribution{
+		id:   id,
+		nix:  d.nix || id == "nixos",
+		guix: d.guix || id == "guix",
+	}
+}
+
+// detect identifies the distribution of root from its os-release file and
+// the presence of a nix or guix store, which may also be used on top of
+// other distributions.
+func detect(root string) distribution {
+	var d distribution
+	release := osRelease(filepath.Join(root, "etc/os-release"))
+	if release == nil {
+		release = osRelease(filepath.Join(root, "usr/lib/os-release"))
+	}
+	d.id = release["ID"]
+	d.like = strings.Fields(release["ID_LIKE"])
+	d.nix = isDir(filepath.Join(root, "nix/store"))
+	d.guix = isDir(filepath.Join(root, "gnu/store"))
+	return d
+}
+
+// osRelease parses the os-release file at path into its variables.
+func osRelease(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(value, `"`):
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+		case strings.HasPrefix(value, "'"):
+			value = strings.Trim(value, "'")
+		}
+		vars[key] = value
+	}
+	return vars
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// newGroups creates the built-in groups for the distribution d installed
+// at root, none of which are evaluated yet.
+func newGroups(root string, d distribution) *groups {
+	return &groups{
+		root:         root,
+		distribution: d,
+		paths:        make(map[string][]*Path),
+	}
+}
+
+// stores are the directories of the package stores of nix and guix, and the
+// profile of the current system of each. On nixos and guix almost every file
+// of /etc is a symlink into the store, as are programs, libraries, and data
+// files.
+func (d distribution) stores() []store {
+	var stores []store
+	if d.nix {
+		stores = append(stores, store{dir: "/nix/store", system: "/run/current-system/sw"})
+	}
+	if d.guix {
+		stores = append(stores, store{dir: "/gnu/store", system: "/run/current-system/profile"})
+	}
+	return stores
+}
+
+type store struct {
+	dir    string
+	system string
+}
+
+// evaluate returns the paths of the built-in group of the given name which
+// exist within the root of g.
+func (g *groups) evaluate(name string) []*Path {
+	root, d := g.root, g.distribution
+	switch name {
+	case "shared":
+		paths := []*Path{
+			File("/dev/null", "rw"),
+			Dir("/lib", "rx"),
+			Dir("/lib64", "rx"),
+			Dir("/usr/lib", "rx"),
+			Dir("/usr/lib64", "rx"),
+			Dir("/usr/libexec", "rx"),
+			Dir("/usr/local/lib", "rx"),
+			Dir("/usr/local/lib64", "rx"),
+			File("/etc/ld.so.conf", "r"),
+			File("/etc/ld.so.cache", "r"),
+			Dir("/etc/ld.so.conf.d", "r"),
+		}
+		for _, s := range d.stores() {
+			paths = append(paths,
+				Dir(s.dir, "rx"),
+				Dir(s.system+"/lib", "rx"),
+			)
+		}
+		return load(root, withLibraryDirs(root, d, paths))
+
+	case "stdio":
+		paths := []*Path{
+			File("/dev/full", "rw"),
+			File("/dev/zero", "r"),
+			File("/dev/fd", "r"),
+			File("/dev/stdin", "rw"),
+			File("/dev/stdout", "rw"),
+			File("/dev/urandom", "r"),
+			Dir("/dev/log", "w"),
+			Dir("/usr/share/locale", "r"),
+			File("/proc/self/cmdline", "r"),
+			File("/usr/share/zoneinfo", "r"),
+			File("/usr/share/common-licenses", "r"),
+			File("/proc/sys/kernel/ngroups_max", "r"),
+			File("/proc/sys/kernel/cap_last_cap", "r"),
+			File("/proc/sys/vm/overcommit_memory", "r"),
+		}
+		for _, s := range d.stores() {
+			paths = append(paths,
+				Dir(s.dir, "r"),
+				Dir("/etc/zoneinfo", "r"),
+				Dir(s.system+"/share/locale", "r"),
+			)
+		}
+		return load(root, paths)
+
+	case "tty":
+		paths := []*Path{
+			File("/dev/tty", "rw"),
+			File("/dev/console", "rw"),
+			File("/etc/terminfo", "r"),
+			Dir("/usr/lib/terminfo", "r"),
+			Dir("/usr/share/terminfo", "r"),
+		}
+		for _, s := range d.stores() {
+			paths = append(paths,
+				Dir(s.dir, "r"),
+				Dir(s.system+"/share/terminfo", "r"),
+			)
+		}
+		return load(root, paths)
+
+	case "tmp":
+		return load(root, []*Path{
+			Dir("/tmp", "rwc"),
+		})
+
+	case "vminfo":
+		return load(root, []*Path{
+			File("/proc/stat", "r"),
+			File("/proc/meminfo", "r"),
+			File("/proc/cpuinfo", "r"),
+			File("/proc/diskstats", "r"),
+			File("/proc/self/maps", "r"),
+			File("/proc/sys/kernel/version", "r"),
+			File("/sys/devices/system/cpu", "r"),
+		})
+
+	case "dns":
+		paths := append([]*Path{
+			File("/etc/hosts", "r"),
+			File("/etc/hostname", "r"),
+			File("/etc/services", "r"),
+			File("/etc/protocols", "r"),
+			File("/etc/resolv.conf", "r"),
+			File("/etc/nsswitch.conf", "r"),
+			File("/etc/host.conf", "r"),
+			File("/etc/gai.conf", "r"),
+		}, dnsFiles(root)...)
+		for _, s := range d.stores() {
+			paths = append(paths, Dir(s.dir, "r"))
+		}
+		return load(root, paths)
+
+	case "certs":
+		// https://cs.opensource.google/go/go/+/refs/tags/go1.19.3:src/crypto/x509/root_linux.go
+		paths := []*Path{
+			Dir("/etc/ssl/certs", "r"),                                     // SLES, Debian
+			Dir("/etc/pki/tls/certs", "r"),                                 // Fedora / RHEL
+			Dir("/sys/etc/security/cacerts", "r"),                          // Android
+			File("/etc/ssl/ca-bundle.pem", "r"),                            // OpenSUSE
+			File("/etc/pki/tls/cacert.pem", "r"),                           // OpenELEC
+			File("/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", "r"), // RHEL 7
+			File("/etc/ssl/cert.pem", "r"),                                 // Alpine
+		}
+		switch {
+		case d.is("debian"):
+			// the certificates of /etc/ssl/certs are symlinks into these
+			paths = append(paths,
+				Dir("/usr/share/ca-certificates", "r"),
+				Dir("/usr/local/share/ca-certificates", "r"),
+			)
+		case d.is("fedora"), d.is("rhel"):
+			// the bundles of /etc/pki/tls/certs are symlinks into these
+			paths = append(paths, Dir("/etc/pki/ca-trust", "r"))
+		}
+		for _, s := range d.stores() {
+			paths = append(paths,
+				Dir(s.dir, "r"),
+				Dir(s.system+"/etc/ssl/certs", "r"),
+			)
+		}
+		return withCertTargets(root, load(root, append(paths, certsFromEnv()...)))
+
+	case "nss":
+		return nssFiles(root, d)
+
+	default:
+		return nil
+	}
+}
+
+// nsswitchDNS are the databases of nsswitch.conf consulted when resolving
+// names, addresses, and ports.
+var nsswitchDNS = []string{"hosts", "networks", "services", "protocols"}
+
+// dnsFiles returns the files of root read when resolving names which are
+// not at fixed locations: the files of databases which nsswitch.conf says
+// are looked up in files or Berkeley DB files, and the targets of
+// /etc/resolv.conf if it is a symlink, as with systemd-resolved.
+func dnsFiles(root string) []*Path {
+	databases := nsswitch(root)
+	var paths []*Path
+	for _, db := range nsswitchDNS {
+		paths = append(paths, databaseFiles(db, databases[db])...)
+	}
+
+	path := "/etc/resolv.conf"
+	for range 8 {
+		target, err := os.Readlink(filepath.Join(root, path))
+		if err != nil {
+			break
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = filepath.Clean(target)
+		paths = append(paths, linkTarget(path))
+	}
+	return paths
+}
+
+// linkTarget returns a Path allowing the target of a symlink to be read.
+// Resolvers and certificate tools commonly replace the target by renaming
+// a new file over it, so the directory of target is allowed rather than
+// the file, but only if the directory is dedicated to it, being below a
+// top-level directory (e.g. /run/systemd/resolve, not /run or /etc).
+func linkTarget(target string) *Path {
+	dir := filepath.Dir(target)
+	if strings.Count(dir, "/") < 2 {
+		return File(target, "r")
+	}
+	return Dir(dir, "r")
+}
+
+// databaseFiles returns the files read for the nsswitch.conf database db
+// by the files, compat, and db sources among sources.
+func databaseFiles(db string, sources []string) []*Path {
+	name := db
+	if db == "initgroups" {
+		name = "group"
+	}
+
+	var paths []*Path
+	for _, source := range sources {
+		switch source {
+		case "files", "compat":
+			paths = append(paths, File("/etc/"+name, "r"))
+		case "db":
+			paths = append(paths,
+				File("/var/lib/misc/"+name+".db", "r"), // Debian
+				File("/var/db/"+name+".db", "r"),
+			)
+		}
+	}
+	return paths
+}
+
+// nssSockets returns the directories of the sockets and memory caches of
+// the daemons answering queries for the NSS modules of the given source.
+func nssSockets(source string) []*Path {
+	switch source {
+	case "sss":
+		return []*Path{
+			Dir("/var/lib/sss/pipes", "rw"),
+			Dir("/var/lib/sss/mc", "r"),
+		}
+	case "systemd":
+		return []*Path{Dir("/run/systemd/userdb", "rw")}
+	case "resolve":
+		return []*Path{Dir("/run/systemd/resolve", "rw")}
+	case "ldap":
+		return []*Path{Dir("/run/nslcd", "rw")} // nss-pam-ldapd
+	}
+	return nil
+}
+
+// nssDatabases are the databases of nsswitch.conf for which the C library
+// uses default sources when they are not configured.
+var nssDatabases = []string{"passwd", "group", "hosts", "networks", "services", "protocols"}
+
+// nssDefault returns the sources used by the C library for the database
+// db when nsswitch.conf does not configure it, or does not exist.
+func nssDefault(db string) []string {
+	if db == "hosts" {
+		return []string{"dns", "files"}
+	}
+	return []string{"files"}
+}
+
+// nssPrivate are the databases of nsswitch.conf holding password hashes,
+// which are not needed to look up users and groups.
+var nssPrivate = []string{"shadow", "gshadow"}
+
+// nssFiles returns the paths of root needed by the C library to look up
+// users, groups, hosts, and so on, as configured by nsswitch.conf: the
+// files of each database, the libnss modules of each source along with
+// the shared libraries they depend on, and the sockets of the daemons
+// the modules query. The databases of nssPrivate are skipped, and those
+// of nssDatabases not configured use their default sources.
+//
+// The socket of nscd is always included, as the C library asks nscd first
+// when it is running, whatever the sources. So are /etc/passwd and
+// /etc/group, which the pure Go implementation of os/user reads directly.
+func nssFiles(root string, d distribution) []*Path {
+	databases := nsswitch(root)
+	if databases == nil {
+		databases = make(map[string][]string)
+	}
+	for _, db := range nssDatabases {
+		if _, exists := databases[db]; !exists {
+			databases[db] = nssDefault(db)
+		}
+	}
+
+	paths := []*Path{
+		File("/etc/nsswitch.conf", "r"),
+		File("/etc/passwd", "r"),
+		File("/etc/group", "r"),
+		Dir("/run/nscd", "rw"),
+		Dir("/var/run/nscd", "rw"),
+	}
+
+	var sources []string
+	for db, list := range databases {
+		if slices.Contains(nssPrivate, db) {
+			continue
+		}
+		paths = append(paths, databaseFiles(db, list)...)
+		sources = append(sources, list...)
+	}
+	slices.Sort(sources)
+	sources = slices.Compact(sources)
+
+	for _, source := range sources {
+		paths = append(paths, nssSockets(source)...)
+	}
+	if d.nix {
+		paths = append(paths, Dir("/nix/store", "r"))
+	}
+	if d.guix {
+		paths = append(paths, Dir("/gnu/store", "r"))
+	}
+	paths = load(root, paths)
+
+	// modules are loaded with dlopen from the library directories, one per
+	// architecture, and need their own dependencies, which are resolved
+	// only on the host as the resolver reads the host's ld.so.cache
+	dirs := libraryDirs(root, ldConfPath, ldCachePath)
+	dirs = append(dirs, "/lib64", "/usr/lib64", "/lib", "/usr/lib")
+	for _, source := range sources {
+		module := "libnss_" + source + ".so.2"
+		for _, dir := range dirs {
+			path := filepath.Join(root, dir, module)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			more, err := executable(path)
+			if root != "/" || err != nil {
+				more = []*Path{File(path, "rx")}
+			}
+			for _, p := range more {
+				if !slices.ContainsFunc(paths, p.Equal) {
+					paths = append(paths, p)
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// nsswitch parses the nsswitch.conf file of root into the sources of each
+// database, omitting any actions such as [NOTFOUND=return].
+func nsswitch(root string) map[string][]string {
+	f, err := os.Open(filepath.Join(root, "etc/nsswitch.conf"))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	databases := make(map[string][]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		db, sources, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		var list []string
+		for _, source := range strings.Fields(sources) {
+			if !strings.HasPrefix(source, "[") && !strings.HasSuffix(source, "]") {
+				list = append(list, source)
+			}
+		}
+		databases[strings.TrimSpace(db)] = list
+	}
+	return databases
+}
+
+// certsFromEnv returns the certificate file and directories given by the
+// SSL_CERT_FILE and SSL_CERT_DIR environment variables, which crypto/x509
+// and OpenSSL read instead of the default locations.
+func certsFromEnv() []*Path {
+	var paths []*Path
+	if file := os.Getenv("SSL_CERT_FILE"); file != "" {
+		paths = append(paths, File(absolute("", file), "r"))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("SSL_CERT_DIR")) {
+		if dir != "" {
+			paths = append(paths, Dir(absolute("", dir), "r"))
+		}
+	}
+	return paths
+}
+
+// withCertTargets returns the paths of root along with the targets of
+// symlinks within the directories of paths, such as the hashed names of
+// certificates, as allowed by linkTarget, if not already covered by paths.
+func withCertTargets(root string, paths []*Path) []*Path {
+	targets := make(map[string]string) // resolved symlinks
+	var more []*Path
+
+	for _, p := range paths {
+		if !p.dir {
+			continue
+		}
+		entries, err := os.ReadDir(p.path)
+		if err != nil {
+			continue
+		}
+		dir := "/"
+		if rel, err := filepath.Rel(root, p.path); err == nil {
+			dir = filepath.Join(dir, rel)
+		}
+		for _, entry := range entries {
+			if entry.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			target, ok := follow(root, filepath.Join(dir, entry.Name()), targets)
+			if !ok {
+				continue
+			}
+			q := linkTarget(target)
+			q.path = filepath.Join(root, q.path)
+			if !slices.ContainsFunc(more, q.Equal) {
+				more = append(more, q)
+			}
+		}
+	}
+
+	for _, q := range more {
+		if !slices.ContainsFunc(paths, func(o *Path) bool {
+			return o.dir && covers(o, q)
+		}) {
+			paths = append(paths, q)
+		}
+	}
+	return paths
+}
+
+// follow returns path with every symlink resolved, like EvalSymlinks, but
+// within root, as if root were the root directory: both path and the
+// result are relative to root, and absolute symlinks are resolved within
+// root. The result for each path resolved is recorded in targets.
+// Certificate directories hold hundreds of symlinks, commonly chained (e.g.
+// a hashed name linking to a named certificate linking to a file
+// elsewhere), so most lookups are answered from targets.
+func follow(root, path string, targets map[string]string) (string, bool) {
+	if path == "/" {
+		return path, true
+	}
+	if target, exists := targets[path]; exists {
+		return target, target != ""
+	}
+	targets[path] = "" // in case of a loop
+
+	parent, ok := follow(root, filepath.Dir(path), targets)
+	if !ok {
+		return "", false
+	}
+	path = filepath.Join(parent, filepath.Base(path))
+
+	target, err := os.Readlink(filepath.Join(root, path))
+	switch {
+	case errors.Is(err, syscall.EINVAL):
+		target = path // not a symlink
+	case err != nil:
+		return "", false
+	default:
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(parent, target)
+		}
+		if target, ok = follow(root, target, targets); !ok {
+			return "", false
+		}
+	}
+
+	targets[path] = target
+	return target, true
+}
+
+// GroupPaths returns the paths of the group with the given name, either
+// one of the built-in groups (e.g. "certs") or a registered group, as they
+// would be allowed by a Locker created now. This is useful for reporting
+// what a group allows on the host, such as the certificates included from
+// SSL_CERT_FILE and SSL_CERT_DIR.
+func GroupPaths(name string) ([]*Path, error) {
+	l := New(Group(name)).(*locker)
+	if l.err != nil {
+		return nil, l.err
+	}
+	paths := l.paths.Slice()
+	slices.SortFunc(paths, func(a, b *Path) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return paths, nil
+}
+
+// withLibraryDirs returns paths along with each directory of shared
+// libraries of root not already covered by paths.
+func withLibraryDirs(root string, d distribution, paths []*Path) []*Path {
+	dirs := libraryDirs(root, ldConfPath, ldCachePath)
+	if d.is("alpine") {
+		dirs = append(dirs, muslDirs(root)...)
+	}
+
+	for _, dir := range dirs {
+		p := Dir(dir, "rx")
+		if !slices.ContainsFunc(paths, func(q *Path) bool {
+			return q.dir && covers(q, p) && p.access()&^q.access() == 0
+		}) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// muslDirs returns the library directories searched by the musl dynamic
+// loader of root, which does not use ld.so.cache.
+func muslDirs(root string) []string {
+	b, err := os.ReadFile(filepath.Join(root, "etc", "ld-musl-"+muslArch()+".path"))
+	if err != nil {
+		return nil // the loader only searches the default directories
+	}
+	return strings.FieldsFunc(string(b), func(r rune) bool {
+		return r == ':' || r == '\n'
+	})
+}
+
+// muslArch returns the name of the architecture used by musl.
+func muslArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i386"
+	case "ppc64le":
+		return "powerpc64le"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// load returns the paths which exist within root, relative to root, each
+// only once.
+func load(root string, paths []*Path) []*Path {
+	result := make([]*Path, 0, len(paths))
+	for _, p := range paths {
+		if root != "/" {
+			c := *p
+			c.path = filepath.Join(root, p.path)
+			p = &c
+		}
+		if slices.ContainsFunc(result, p.Equal) {
+			continue
+		}
+		if _, err := os.Stat(p.path); err == nil {
+			result = append(result, p)
+		}
+	}
+	return result
+}
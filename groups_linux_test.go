@@ -0,0 +1,676 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"maps"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/go-set/v3"
+	"github.com/shoenig/test/must"
+)
+
+// fakeRoot creates a root directory containing the given directories and
+// files, where each file is given its content.
+func fakeRoot(t *testing.T, dirs []string, files map[string]string) string {
+	root := t.TempDir()
+	for _, dir := range dirs {
+		must.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		must.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		writeFile(t, path, content, 0o644)
+	}
+	return root
+}
+
+// builtin returns the paths of the named built-in group of g.
+func builtin(g *groups, name string) []*Path {
+	paths, _ := g.named(name)
+	return paths
+}
+
+func Test_osRelease(t *testing.T) {
+	root := fakeRoot(t, nil, map[string]string{
+		"etc/os-release": `# comment
+NAME="Ubuntu"
+ID=ubuntu
+ID_LIKE='debian'
+PRETTY_NAME="Ubuntu \"Noble\""
+`,
+	})
+	vars := osRelease(filepath.Join(root, "etc/os-release"))
+	must.Eq(t, map[string]string{
+		"NAME":        "Ubuntu",
+		"ID":          "ubuntu",
+		"ID_LIKE":     "debian",
+		"PRETTY_NAME": `Ubuntu "Noble"`,
+	}, vars)
+
+	must.Nil(t, osRelease(filepath.Join(root, "missing")))
+}
+
+func Test_detect(t *testing.T) {
+	cases := []struct {
+		name string
+		root string
+		exp  distribution
+	}{
+		{
+			name: "ubuntu",
+			root: fakeRoot(t, nil, map[string]string{
+				"etc/os-release": "ID=ubuntu\nID_LIKE=debian\n",
+			}),
+			exp: distribution{id: "ubuntu", like: []string{"debian"}},
+		},
+		{
+			name: "nixos",
+			root: fakeRoot(t, []string{"nix/store"}, map[string]string{
+				"etc/os-release": "ID=nixos\n",
+			}),
+			exp: distribution{id: "nixos", like: []string{}, nix: true},
+		},
+		{
+			name: "guix",
+			root: fakeRoot(t, []string{"gnu/store"}, map[string]string{
+				"usr/lib/os-release": "ID=guix\n",
+			}),
+			exp: distribution{id: "guix", like: []string{}, guix: true},
+		},
+		{
+			name: "nix_on_fedora",
+			root: fakeRoot(t, []string{"nix/store"}, map[string]string{
+				"etc/os-release": "ID=fedora\n",
+			}),
+			exp: distribution{id: "fedora", like: []string{}, nix: true},
+		},
+		{
+			name: "unknown",
+			root: fakeRoot(t, nil, nil),
+			exp:  distribution{like: []string{}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := detect(tc.root)
+			must.Eq(t, tc.exp.id, d.id)
+			must.SliceContainsAll(t, tc.exp.like, d.like)
+			must.Eq(t, tc.exp.nix, d.nix)
+			must.Eq(t, tc.exp.guix, d.guix)
+		})
+	}
+}
+
+func Test_newGroups(t *testing.T) {
+	// contains returns whether paths contains the rule of kind, mode, and
+	// path relative to root
+	contains := func(paths []*Path, root, s string) bool {
+		q, err := ParsePath(s)
+		must.NoError(t, err)
+		q.path = filepath.Join(root, q.path)
+		for _, p := range paths {
+			if p.Equal(q) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("nixos", func(t *testing.T) {
+		root := fakeRoot(t, []string{
+			"nix/store",
+			"etc/ssl/certs",
+			"run/current-system/sw/lib",
+			"run/current-system/sw/share/terminfo",
+		}, map[string]string{
+			"etc/os-release": "ID=nixos\n",
+		})
+		g := newGroups(root, detect(root))
+		must.True(t, contains(builtin(g, "shared"), root, "d:rx:/nix/store"))
+		must.True(t, contains(builtin(g, "shared"), root, "d:rx:/run/current-system/sw/lib"))
+		must.False(t, contains(builtin(g, "shared"), root, "d:rx:/usr/lib"))
+		must.True(t, contains(builtin(g, "certs"), root, "d:r:/nix/store"))
+		must.True(t, contains(builtin(g, "certs"), root, "d:r:/etc/ssl/certs"))
+		must.True(t, contains(builtin(g, "dns"), root, "d:r:/nix/store"))
+		must.True(t, contains(builtin(g, "tty"), root, "d:r:/run/current-system/sw/share/terminfo"))
+		must.SliceEmpty(t, builtin(g, "tmp"))
+	})
+
+	t.Run("guix", func(t *testing.T) {
+		root := fakeRoot(t, []string{"gnu/store"}, map[string]string{
+			"etc/os-release": "ID=guix\n",
+		})
+		g := newGroups(root, detect(root))
+		must.True(t, contains(builtin(g, "shared"), root, "d:rx:/gnu/store"))
+		must.True(t, contains(builtin(g, "certs"), root, "d:r:/gnu/store"))
+		must.False(t, contains(builtin(g, "certs"), root, "d:r:/nix/store"))
+	})
+
+	t.Run("debian", func(t *testing.T) {
+		root := fakeRoot(t, []string{
+			"etc/ssl/certs",
+			"usr/share/ca-certificates",
+			"usr/lib/x86_64-linux-gnu",
+			"opt/vendor/lib",
+		}, map[string]string{
+			"etc/os-release":               "ID=ubuntu\nID_LIKE=debian\n",
+			"etc/ld.so.conf":               "include /etc/ld.so.conf.d/*.conf\n",
+			"etc/ld.so.conf.d/vendor.conf": "/opt/vendor/lib\n",
+		})
+
+		g := newGroups(root, detect(root))
+		must.True(t, contains(builtin(g, "certs"), root, "d:r:/usr/share/ca-certificates"))
+		must.True(t, contains(builtin(g, "shared"), root, "d:rx:/opt/vendor/lib"))
+		must.True(t, contains(builtin(g, "shared"), root, "f:r:/etc/ld.so.conf"))
+		must.False(t, contains(builtin(g, "shared"), root, "d:rx:/nix/store"))
+	})
+
+	t.Run("alpine", func(t *testing.T) {
+		root := fakeRoot(t, []string{"lib", "usr/lib", "opt/vendor/lib"}, map[string]string{
+			"etc/os-release":                      "ID=alpine\n",
+			"etc/ld-musl-" + muslArch() + ".path": "/lib:/usr/lib\n/opt/vendor/lib\n",
+		})
+		g := newGroups(root, detect(root))
+		must.True(t, contains(builtin(g, "shared"), root, "d:rx:/lib"))
+		must.True(t, contains(builtin(g, "shared"), root, "d:rx:/opt/vendor/lib"))
+	})
+}
+
+func TestSetDistribution(t *testing.T) {
+	t.Cleanup(func() { SetDistribution("") })
+
+	host := Distribution()
+	must.Eq(t, detect("/").id, host)
+
+	SetDistribution("nixos")
+	must.Eq(t, "nixos", Distribution())
+	must.Eq(t, "nixos", groupsOf("/").distribution.id)
+
+	SetDistribution("")
+	must.Eq(t, host, Distribution())
+}
+
+func Test_merge(t *testing.T) {
+	s := set.NewHashSet[*Path](4)
+	s.Insert(File("/etc/hosts", "r"))
+	merge(s, []*Path{
+		File("/etc/hosts", "w"),
+		Dir("/nix/store", "r"),
+	})
+	merge(s, []*Path{
+		Dir("/nix/store", "rx"),
+	})
+	must.SliceContainsAll(t, []*Path{
+		File("/etc/hosts", "rw"),
+		Dir("/nix/store", "rx"),
+	}, s.Slice())
+}
+
+func TestNew_explicitGroupPath(t *testing.T) {
+	t.Cleanup(RefreshGroups)
+
+	root := fakeRoot(t, nil, map[string]string{
+		"etc/hosts": "127.0.0.1 localhost\n",
+	})
+	hosts := filepath.Join(root, "etc/hosts")
+
+	// a path given both by a group and explicitly gets both modes, and is
+	// no longer builtin, whichever comes first
+	for _, l := range []Locker{
+		New(GroupRoot(root), DNS(), File(hosts, "w")),
+		New(GroupRoot(root), File(hosts, "w"), DNS()),
+	} {
+		paths := l.(*locker).paths.Slice()
+		must.SliceContainsAll(t, []*Path{File(hosts, "rw")}, paths)
+		must.False(t, paths[0].builtin)
+	}
+}
+
+func TestRefreshGroups(t *testing.T) {
+	t.Cleanup(RefreshGroups)
+
+	root := fakeRoot(t, nil, map[string]string{
+		"etc/hosts": "127.0.0.1 localhost\n",
+	})
+	resolv := filepath.Join(root, "etc/resolv.conf")
+
+	paths := func() string {
+		return New(GroupRoot(root), DNS()).(*locker).paths.String()
+	}
+	must.Eq(t, "[(r:file:"+filepath.Join(root, "etc/hosts")+")]", paths())
+
+	// the groups are cached
+	writeFile(t, resolv, "nameserver 127.0.0.53\n", 0o644)
+	must.StrNotContains(t, paths(), resolv)
+
+	RefreshGroups()
+	must.StrContains(t, paths(), resolv)
+}
+
+func TestNew_lazyGroups(t *testing.T) {
+	t.Cleanup(RefreshGroups)
+
+	root := fakeRoot(t, []string{"tmp"}, nil)
+	evaluatedOf := func() []string {
+		groupsLock.Lock()
+		defer groupsLock.Unlock()
+		g, exists := evaluated[root]
+		if !exists {
+			return nil
+		}
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		names := slices.Collect(maps.Keys(g.paths))
+		slices.Sort(names)
+		return names
+	}
+
+	// no group is used, so none is evaluated
+	_ = New(GroupRoot(root), Dir("/tmp", "r"))
+	must.Nil(t, evaluatedOf())
+
+	// only the groups used are evaluated
+	_ = New(GroupRoot(root), Tmp(), DNS())
+	must.Eq(t, []string{"dns", "tmp"}, evaluatedOf())
+}
+
+func TestGroupRoot(t *testing.T) {
+	t.Cleanup(RefreshGroups)
+
+	root := fakeRoot(t, []string{"tmp"}, map[string]string{
+		"etc/os-release": "ID=alpine\n",
+	})
+	l := New(GroupRoot(root), Tmp(), Certs()).(*locker)
+	must.Eq(t, []*Path{Dir(filepath.Join(root, "tmp"), "rwc")}, l.paths.Slice())
+	must.Eq(t, "alpine", groupsOf(root).distribution.id)
+
+	// the groups of the host are unaffected
+	must.Eq(t, detect("/").id, Distribution())
+}
+
+func TestRegisterGroup(t *testing.T) {
+	t.Cleanup(func() {
+		registeredLock.Lock()
+		defer registeredLock.Unlock()
+		clear(registered)
+	})
+
+	RegisterGroup("fruits", Dir("tests/fruits", "r"))
+	RegisterGroup("produce",
+		Group("fruits"),
+		Group("tmp"),
+		File("tests/veggies/corn.txt", "r"),
+	)
+
+	t.Run("string", func(t *testing.T) {
+		l := New(Group("produce"), DNS(), File("/etc/passwd", "r"))
+		must.Eq(t, "[g:dns g:produce r:/etc/passwd]", l.String())
+	})
+
+	t.Run("expanded", func(t *testing.T) {
+		cwd, err := os.Getwd()
+		must.NoError(t, err)
+		l := New(Group("produce")).(*locker)
+		must.SliceContainsAll(t, []*Path{
+			Dir(filepath.Join(cwd, "tests/fruits"), "r"),
+			File(filepath.Join(cwd, "tests/veggies/corn.txt"), "r"),
+			Dir("/tmp", "rwc"),
+		}, l.paths.Slice())
+		must.NoError(t, l.Check(Mandatory))
+	})
+
+	t.Run("relative", func(t *testing.T) {
+		l := New(BaseDir("/srv/app"), Group("fruits")).(*locker)
+		must.Eq(t, []*Path{Dir("/srv/app/tests/fruits", "r")}, l.paths.Slice())
+	})
+
+	t.Run("parsed", func(t *testing.T) {
+		p, err := ParsePath("g::fruits")
+		must.NoError(t, err)
+		l := New(p)
+		must.Eq(t, "[g:fruits]", l.String())
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		l := New(Group("missing"))
+		err := l.Check(Mandatory)
+		must.ErrorIs(t, err, ErrGroupNotFound)
+		must.ErrorContains(t, err, "missing")
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		must.Panic(t, func() { RegisterGroup("fruits") })
+		must.Panic(t, func() { RegisterGroup("certs") })
+		must.Panic(t, func() { RegisterGroup("a:b") })
+		must.Panic(t, func() { RegisterGroup("later", Group("unregistered")) })
+		must.Panic(t, func() { RegisterGroup("options", Hardened()) })
+	})
+}
+
+func Test_newGroups_certsEnv(t *testing.T) {
+	root := fakeRoot(t, []string{
+		"etc/ssl/certs",
+		"opt/certs",
+		"usr/share/ca-certificates/mozilla",
+	}, map[string]string{
+		"etc/os-release":                             "ID=unknown\n",
+		"opt/bundle.pem":                             "bundle",
+		"srv/ca/internal.pem":                        "internal",
+		"usr/share/ca-certificates/mozilla/root.crt": "root",
+		"usr/local/share/ca/local.crt":               "local",
+		"etc/hostname":                               "box",
+	})
+	must.NoError(t, os.Symlink("../../srv/ca/internal.pem", filepath.Join(root, "opt/certs/1a2b3c4d.0")))
+	must.NoError(t, os.Symlink("../../../usr/share/ca-certificates/mozilla/root.crt", filepath.Join(root, "etc/ssl/certs/5e6f7a8b.0")))
+	must.NoError(t, os.Symlink("missing.pem", filepath.Join(root, "opt/certs/dangling.0")))
+	must.NoError(t, os.Symlink("/usr/local/share/ca/local.crt", filepath.Join(root, "opt/certs/local.0")))
+	must.NoError(t, os.Symlink("/etc/hostname", filepath.Join(root, "opt/certs/hostname.0")))
+
+	t.Setenv("SSL_CERT_FILE", "/opt/bundle.pem")
+	t.Setenv("SSL_CERT_DIR", "/opt/certs::/does/not/exist")
+
+	g := newGroups(root, detect(root))
+	must.SliceContainsAll(t, []*Path{
+		Dir(filepath.Join(root, "etc/ssl/certs"), "r"),
+		Dir(filepath.Join(root, "usr/share/ca-certificates/mozilla"), "r"),
+		File(filepath.Join(root, "opt/bundle.pem"), "r"),
+		Dir(filepath.Join(root, "opt/certs"), "r"),
+		Dir(filepath.Join(root, "srv/ca"), "r"),
+		Dir(filepath.Join(root, "usr/local/share/ca"), "r"),
+		File(filepath.Join(root, "etc/hostname"), "r"),
+	}, builtin(g, "certs"))
+
+	// a target in /etc never allows all of /etc
+	must.SliceNotContains(t, builtin(g, "certs"), Dir(filepath.Join(root, "etc"), "r"))
+}
+
+func TestGroupPaths(t *testing.T) {
+	t.Cleanup(RefreshGroups)
+
+	dir := t.TempDir()
+	t.Setenv("SSL_CERT_DIR", dir)
+	RefreshGroups()
+
+	paths, err := GroupPaths("certs")
+	must.NoError(t, err)
+	must.SliceContains(t, paths, Dir(dir, "r"))
+
+	_, err = GroupPaths("missing")
+	must.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestLocker_Certs(t *testing.T) {
+	cases := map[string]func(){
+		"env": func() {
+			dir, err := os.MkdirTemp("", "certs")
+			must.NoError(t, err)
+			writeFile(t, filepath.Join(dir, "ca.pem"), "not really", 0o644)
+			must.NoError(t, os.Setenv("SSL_CERT_DIR", dir))
+			RefreshGroups()
+
+			err = New(Certs()).Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile(filepath.Join(dir, "ca.pem"))
+			must.NoError(t, err)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_Certs", cases)
+}
+
+func Test_follow(t *testing.T) {
+	root := fakeRoot(t, []string{"usr/share/ca/mozilla", "etc/pki/certs"}, map[string]string{
+		"usr/share/ca/mozilla/root.crt": "root",
+	})
+	link := func(target, name string) {
+		must.NoError(t, os.Symlink(target, filepath.Join(root, name)))
+	}
+	link("pki/certs", "etc/certs")
+	link("../../../usr/share/ca/mozilla/root.crt", "etc/pki/certs/root.pem")
+	link("root.pem", "etc/pki/certs/1a2b3c4d.0")
+	link("loop.b", "etc/pki/certs/loop.a")
+	link("loop.a", "etc/pki/certs/loop.b")
+	link("missing.pem", "etc/pki/certs/dangling.0")
+	link("/usr/share/ca/mozilla/root.crt", "etc/pki/certs/absolute.pem")
+	link("../../../../../usr/share/ca/mozilla/root.crt", "etc/pki/certs/above.pem")
+
+	targets := make(map[string]string)
+	for _, name := range []string{
+		"etc/certs/1a2b3c4d.0", // through a symlinked directory
+		"etc/pki/certs/1a2b3c4d.0",
+		"etc/pki/certs/root.pem",
+		"usr/share/ca/mozilla/root.crt",
+		"etc/pki/certs/absolute.pem", // within root, not the host
+		"etc/pki/certs/above.pem",    // cannot escape root
+	} {
+		target, ok := follow(root, "/"+name, targets)
+		must.True(t, ok)
+		must.Eq(t, "/usr/share/ca/mozilla/root.crt", target)
+	}
+
+	for _, name := range []string{"etc/pki/certs/loop.a", "etc/pki/certs/dangling.0"} {
+		_, ok := follow(root, "/"+name, targets)
+		must.False(t, ok)
+	}
+}
+
+func Test_nsswitch(t *testing.T) {
+	root := fakeRoot(t, nil, map[string]string{
+		"etc/nsswitch.conf": `# comment
+passwd:   files systemd
+hosts:    files [NOTFOUND=return] mdns4_minimal dns # trailing
+services: db files
+
+bogus line
+`,
+	})
+	must.Eq(t, map[string][]string{
+		"passwd":   {"files", "systemd"},
+		"hosts":    {"files", "mdns4_minimal", "dns"},
+		"services": {"db", "files"},
+	}, nsswitch(root))
+
+	must.Nil(t, nsswitch(t.TempDir()))
+}
+
+func Test_newGroups_dns(t *testing.T) {
+	root := fakeRoot(t, []string{"run/systemd/resolve"}, map[string]string{
+		"etc/os-release":                       "ID=ubuntu\n",
+		"etc/hosts":                            "127.0.0.1 localhost\n",
+		"etc/hostname":                         "box\n",
+		"etc/networks":                         "link-local 169.254.0.0\n",
+		"etc/gai.conf":                         "",
+		"etc/nsswitch.conf":                    "hosts: files dns\nnetworks: files\nservices: db files\n",
+		"var/lib/misc/services.db":             "",
+		"run/systemd/resolve/stub-resolv.conf": "nameserver 127.0.0.53\n",
+	})
+	must.NoError(t, os.Symlink("../run/systemd/resolve/stub-resolv.conf", filepath.Join(root, "etc/resolv.conf")))
+
+	g := newGroups(root, detect(root))
+	join := func(path string) string { return filepath.Join(root, path) }
+	must.SliceContainsAll(t, []*Path{
+		File(join("/etc/hosts"), "r"),
+		File(join("/etc/hostname"), "r"),
+		File(join("/etc/resolv.conf"), "r"),
+		File(join("/etc/nsswitch.conf"), "r"),
+		File(join("/etc/gai.conf"), "r"),
+		File(join("/etc/networks"), "r"),
+		File(join("/var/lib/misc/services.db"), "r"),
+		Dir(join("/run/systemd/resolve"), "r"),
+	}, builtin(g, "dns"))
+}
+
+func Test_dnsFiles_resolvInEtc(t *testing.T) {
+	root := fakeRoot(t, []string{"etc/resolvconf/run"}, map[string]string{
+		"etc/resolv.conf.dhcp":           "nameserver 10.0.0.1\n",
+		"etc/resolvconf/run/resolv.conf": "nameserver 10.0.0.2\n",
+	})
+	link := filepath.Join(root, "etc/resolv.conf")
+
+	// a target within /etc is allowed alone
+	must.NoError(t, os.Symlink("resolv.conf.dhcp", link))
+	paths := dnsFiles(root)
+	must.SliceContains(t, paths, File("/etc/resolv.conf.dhcp", "r"))
+	must.SliceNotContains(t, paths, Dir("/etc", "r"))
+
+	// a directory of the resolver beneath /etc is allowed
+	must.NoError(t, os.Remove(link))
+	must.NoError(t, os.Symlink("/etc/resolvconf/run/resolv.conf", link))
+	must.SliceContains(t, dnsFiles(root), Dir("/etc/resolvconf/run", "r"))
+
+	// a target directly in another top-level directory is allowed alone
+	must.NoError(t, os.Remove(link))
+	must.NoError(t, os.Symlink("/run/resolv.conf", link))
+	paths = dnsFiles(root)
+	must.SliceContains(t, paths, File("/run/resolv.conf", "r"))
+	must.SliceNotContains(t, paths, Dir("/run", "r"))
+}
+
+func TestLocker_DNS(t *testing.T) {
+	cases := map[string]func(){
+		"lookup": func() {
+			err := New(DNS()).Lock(Mandatory)
+			must.NoError(t, err)
+
+			addrs, err := net.DefaultResolver.LookupHost(t.Context(), "localhost")
+			must.NoError(t, err)
+			must.SliceNotEmpty(t, addrs)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_DNS", cases)
+}
+
+func Test_newGroups_nss(t *testing.T) {
+	root := fakeRoot(t, []string{"var/lib/sss/pipes", "run/systemd/userdb", "run/nscd"}, map[string]string{
+		"etc/os-release":               "ID=fedora\n",
+		"etc/nsswitch.conf":            "passwd: sss files systemd\ngroup: files [SUCCESS=merge] sss\ninitgroups: files\nnetgroup: nis\nshadow: files compat\ngshadow: files\n",
+		"etc/passwd":                   "root:x:0:0:root:/root:/bin/sh\n",
+		"etc/group":                    "root:x:0:\n",
+		"etc/shadow":                   "root:*:19000::::::\n",
+		"etc/gshadow":                  "root:*::\n",
+		"usr/lib64/libnss_compat.so.2": "",
+		"etc/netgroup":                 "",
+		"usr/lib64/libnss_sss.so.2":    "",
+		"usr/lib64/libnss_nis.so.2":    "",
+		"usr/lib64/libnss_mdns.so.2":   "",
+	})
+
+	g := newGroups(root, detect(root))
+	join := func(path string) string { return filepath.Join(root, path) }
+	must.SliceContainsAll(t, []*Path{
+		File(join("/etc/nsswitch.conf"), "r"),
+		File(join("/etc/passwd"), "r"),
+		File(join("/etc/group"), "r"),
+		Dir(join("/run/nscd"), "rw"),
+		Dir(join("/var/lib/sss/pipes"), "rw"),
+		Dir(join("/run/systemd/userdb"), "rw"),
+		File(join("/usr/lib64/libnss_sss.so.2"), "rx"),
+		File(join("/usr/lib64/libnss_nis.so.2"), "rx"),
+	}, builtin(g, "nss"))
+
+	// password hashes are never included, netgroup is not looked up in
+	// files, and mdns is not configured
+	must.SliceNotContains(t, builtin(g, "nss"), File(join("/etc/shadow"), "r"))
+	must.SliceNotContains(t, builtin(g, "nss"), File(join("/etc/gshadow"), "r"))
+	must.SliceNotContains(t, builtin(g, "nss"), File(join("/usr/lib64/libnss_compat.so.2"), "rx"))
+	must.SliceNotContains(t, builtin(g, "nss"), File(join("/etc/netgroup"), "r"))
+	must.SliceNotContains(t, builtin(g, "nss"), File(join("/usr/lib64/libnss_mdns.so.2"), "rx"))
+}
+
+func Test_nssSockets(t *testing.T) {
+	// groups mark their paths as builtin, so each call builds new ones
+	for _, source := range []string{"sss", "systemd", "resolve", "ldap"} {
+		first, second := nssSockets(source), nssSockets(source)
+		must.SliceNotEmpty(t, first)
+		for i, p := range first {
+			must.False(t, p.builtin)
+			must.True(t, p != second[i])
+		}
+	}
+	must.SliceEmpty(t, nssSockets("files"))
+}
+
+func Test_newGroups_nssDefaults(t *testing.T) {
+	t.Run("no_nsswitch", func(t *testing.T) {
+		root := fakeRoot(t, nil, map[string]string{
+			"etc/passwd": "root:x:0:0:root:/root:/bin/sh\n",
+			"etc/group":  "root:x:0:\n",
+			"etc/hosts":  "127.0.0.1 localhost\n",
+		})
+		g := newGroups(root, detect(root))
+		must.SliceContainsSubsetEqual(t, builtin(g, "nss"), []*Path{
+			File(filepath.Join(root, "etc/passwd"), "r"),
+			File(filepath.Join(root, "etc/group"), "r"),
+			File(filepath.Join(root, "etc/hosts"), "r"),
+		})
+	})
+
+	t.Run("not_files", func(t *testing.T) {
+		// pure Go os/user reads the files whatever the sources
+		root := fakeRoot(t, nil, map[string]string{
+			"etc/nsswitch.conf": "passwd: sss\ngroup: sss\n",
+			"etc/passwd":        "root:x:0:0:root:/root:/bin/sh\n",
+			"etc/group":         "root:x:0:\n",
+		})
+		g := newGroups(root, detect(root))
+		must.SliceContainsSubsetEqual(t, builtin(g, "nss"), []*Path{
+			File(filepath.Join(root, "etc/passwd"), "r"),
+			File(filepath.Join(root, "etc/group"), "r"),
+		})
+	})
+}
+
+func TestLocker_NSS(t *testing.T) {
+	cases := map[string]func(){
+		"lookup": func() {
+			err := New(NSS()).Lock(Mandatory)
+			must.NoError(t, err)
+
+			u, err := user.LookupId("0")
+			must.NoError(t, err)
+			must.Eq(t, "root", u.Username)
+
+			g, err := user.LookupGroupId("0")
+			must.NoError(t, err)
+			must.Eq(t, "root", g.Name)
+		},
+		"denied": func() {
+			err := New(File("/dev/null", "r")).Lock(Mandatory)
+			must.NoError(t, err)
+
+			_, err = user.LookupId("0")
+			must.Error(t, err)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_NSS", cases)
+}
@@ -0,0 +1,278 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// trampolineRun is the marker argument indicating the process was started
+// by RunIsolated to restrict itself before running a registered function.
+const trampolineRun = "-landlock-trampoline-run"
+
+var (
+	// ErrNotRegistered indicates a function was not registered by name
+	ErrNotRegistered = errors.New("function not registered")
+
+	// ErrWorkerExited indicates an isolated process exited without
+	// providing a result
+	ErrWorkerExited = errors.New("isolated process exited")
+)
+
+var (
+	functionsLock sync.RWMutex
+	functions     = make(map[string]serveFunc)
+)
+
+// serveFunc decodes each input from dec, calls a registered function, and
+// encodes each result to enc, until there is no more input.
+type serveFunc func(dec *gob.Decoder, enc *gob.Encoder) error
+
+// response is the result of one call to a registered function.
+type response[O any] struct {
+	Output O
+	Err    string
+}
+
+// pending is the function to be run by Trampoline, if the process was
+// started by RunIsolated.
+var pending *isolated
+
+type isolated struct {
+	name string
+	req  *os.File
+	resp *os.File
+}
+
+func init() {
+	if len(os.Args) > 1 && os.Args[1] == trampolineRun {
+		isolate(os.Args[2:])
+	}
+}
+
+// Register makes fn available to RunIsolated under the given name.
+//
+// Register must be called before Trampoline, typically from an init
+// function, so that the function is registered in both the parent and the
+// isolated child process. Input and output values are encoded using gob.
+//
+// Register panics if name is already registered.
+func Register[I, O any](name string, fn func(I) (O, error)) {
+	functionsLock.Lock()
+	defer functionsLock.Unlock()
+
+	if _, exists := functions[name]; exists {
+		panic("landlock: function already registered: " + name)
+	}
+
+	functions[name] = func(dec *gob.Decoder, enc *gob.Encoder) error {
+		for {
+			var input I
+			if err := dec.Decode(&input); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			output, err := call(fn, input)
+			r := response[O]{Output: output}
+			if err != nil {
+				r.Err = err.Error()
+			}
+			if err = enc.Encode(&r); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func call[I, O any](fn func(I) (O, error), input I) (output O, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(input)
+}
+
+func lookup(name string) (serveFunc, bool) {
+	functionsLock.RLock()
+	defer functionsLock.RUnlock()
+	f, exists := functions[name]
+	return f, exists
+}
+
+// Trampoline runs the registered function requested by RunIsolated, if the
+// process was started by RunIsolated, and then exits. Otherwise Trampoline
+// returns immediately.
+//
+// Programs using RunIsolated must call Trampoline at the start of main, or
+// in TestMain for tests, after all functions have been registered.
+//
+// The isolated process restricts itself during initialization of this
+// package, before the initialization of any package importing it, so all
+// of main (including Trampoline) runs restricted.
+func Trampoline() {
+	if pending == nil {
+		return
+	}
+
+	serve, exists := lookup(pending.name)
+	if !exists {
+		_, _ = fmt.Fprintf(os.Stderr, "landlock: %v: %s\n", ErrNotRegistered, pending.name)
+		os.Exit(2)
+	}
+
+	dec := gob.NewDecoder(pending.req)
+	enc := gob.NewEncoder(pending.resp)
+	if err := serve(dec, enc); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "landlock: %s: %v\n", pending.name, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// isolate runs in the child process started by RunIsolated; it restricts
+// the process using the inherited ruleset, and records the function to be
+// run once Trampoline is called.
+func isolate(args []string) {
+	status, args := restrictTrampoline(args, enforce)
+	_ = status.Close() // success
+
+	req, _ := strconv.Atoi(args[1])
+	resp, _ := strconv.Atoi(args[2])
+	pending = &isolated{
+		name: args[0],
+		req:  os.NewFile(uintptr(req), "landlock-request"),
+		resp: os.NewFile(uintptr(resp), "landlock-response"),
+	}
+}
+
+// RunIsolated runs the function registered under name in a child process
+// restricted by l, returning the output of the function.
+//
+// The child process is a re-execution of the current binary, which must
+// call Trampoline. The parent process is not restricted. The child process
+// is killed if ctx is done before the function returns.
+//
+// An error is returned if the child process could not be restricted, if
+// the function returns an error or panics, or if the child process exits
+// without providing a result.
+func RunIsolated[I, O any](ctx context.Context, l Locker, name string, input I) (O, error) {
+	var output O
+
+	w, err := startWorker(ctx, l, name)
+	if err != nil {
+		return output, err
+	}
+
+	err = w.enc.Encode(&input)
+	_ = w.req.Close() // only one input
+	if err != nil {
+		return output, errors.Join(w.stop(ctx), err)
+	}
+
+	var r response[O]
+	if err = w.dec.Decode(&r); err != nil {
+		if err = w.stop(ctx); err == nil {
+			err = ErrWorkerExited
+		}
+		return output, err
+	}
+	_ = w.stop(ctx)
+
+	if r.Err != "" {
+		return r.Output, errors.New(r.Err)
+	}
+	return r.Output, nil
+}
+
+// worker is a child process serving a registered function.
+type worker struct {
+	cmd  *Cmd
+	req  *os.File // write end of requests
+	resp *os.File // read end of responses
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+}
+
+// startWorker starts a child process restricted by l, serving the function
+// registered under name.
+func startWorker(ctx context.Context, l Locker, name string) (*worker, error) {
+	if _, exists := lookup(name); !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegistered, name)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := Ruleset(l)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rs.Close() }()
+
+	cmd := CommandContext(ctx, l, self)
+	cmd.Stderr = os.Stderr
+
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		_ = reqR.Close()
+		_ = reqW.Close()
+		return nil, err
+	}
+
+	n := 3 + 2 // after the ruleset and status pipe
+	err = cmd.start(rs, trampolineRun, []*os.File{reqR, respW},
+		name,
+		strconv.Itoa(n),   // requests
+		strconv.Itoa(n+1), // responses
+	)
+	_ = reqR.Close()
+	_ = respW.Close()
+	if err != nil {
+		_ = reqW.Close()
+		_ = respR.Close()
+		return nil, err
+	}
+
+	return &worker{
+		cmd:  cmd,
+		req:  reqW,
+		resp: respR,
+		enc:  gob.NewEncoder(reqW),
+		dec:  gob.NewDecoder(respR),
+	}, nil
+}
+
+// stop closes the pipes of w and waits for the child process to exit,
+// returning the reason the child process exited, if unexpected.
+func (w *worker) stop(ctx context.Context) error {
+	_ = w.req.Close()
+	_ = w.resp.Close()
+	err := w.cmd.Wait()
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil:
+		return fmt.Errorf("%w: %w", ErrWorkerExited, err)
+	default:
+		return nil
+	}
+}
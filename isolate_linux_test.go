@@ -0,0 +1,101 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+)
+
+func init() {
+	Register("upper", func(s string) (string, error) {
+		return strings.ToUpper(s), nil
+	})
+	Register("read", func(path string) (string, error) {
+		b, err := os.ReadFile(path)
+		return string(b), err
+	})
+	Register("panic", func(s string) (string, error) {
+		panic(s)
+	})
+	Register("sleep", func(d time.Duration) (int, error) {
+		time.Sleep(d)
+		return 1, nil
+	})
+}
+
+func TestMain(m *testing.M) {
+	Trampoline()
+	os.Exit(m.Run())
+}
+
+func TestRunIsolated(t *testing.T) {
+	t.Run("upper", func(t *testing.T) {
+		result, err := RunIsolated[string, string](t.Context(), New(), "upper", "hello")
+		must.NoError(t, err)
+		must.Eq(t, "HELLO", result)
+	})
+
+	t.Run("read_allowed", func(t *testing.T) {
+		l := New(File("tests/fruits/apple.txt", "r"))
+		result, err := RunIsolated[string, string](t.Context(), l, "read", "tests/fruits/apple.txt")
+		must.NoError(t, err)
+		must.Eq(t, "apple\n\n", result)
+	})
+
+	t.Run("read_denied", func(t *testing.T) {
+		l := New(File("tests/fruits/apple.txt", "r"))
+		_, err := RunIsolated[string, string](t.Context(), l, "read", "tests/fruits/banana.txt")
+		must.ErrorContains(t, err, "permission denied")
+
+		// the parent process is not restricted
+		_, err = os.ReadFile("tests/fruits/banana.txt")
+		must.NoError(t, err)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		_, err := RunIsolated[string, string](t.Context(), New(), "panic", "oops")
+		must.ErrorContains(t, err, "panic: oops")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+		_, err := RunIsolated[time.Duration, int](ctx, New(), "sleep", time.Minute)
+		must.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("not_registered", func(t *testing.T) {
+		_, err := RunIsolated[string, string](t.Context(), New(), "missing", "")
+		must.ErrorIs(t, err, ErrNotRegistered)
+	})
+
+	t.Run("lock_failure", func(t *testing.T) {
+		l := New(File("tests/fruits/missing.txt", "r"))
+		_, err := RunIsolated[string, string](t.Context(), l, "upper", "hello")
+		must.ErrorIs(t, err, ErrLandlockFailedToLock)
+	})
+
+	t.Run("foreign_locker", func(t *testing.T) {
+		_, err := RunIsolated[string, string](t.Context(), foreignLocker{New()}, "upper", "hello")
+		must.ErrorIs(t, err, ErrForeignLocker)
+	})
+}
+
+func TestRegister_duplicate(t *testing.T) {
+	defer func() {
+		r := recover()
+		must.NotNil(t, r)
+	}()
+	Register("upper", func(s string) (string, error) {
+		return s, nil
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"errors"
+	"os"
+)
+
+// Jail opens dir as an *os.Root and locks the process so that only dir
+// is accessible, at the given mode.
+//
+// The returned Root restricts path resolution for code which uses it, and
+// the kernel enforces the same boundary for all code in the process, which
+// includes code which does not use the Root. The rule is created from the
+// directory opened by the Root, so it cannot be swapped for a different
+// directory in between.
+//
+// In addition to dir, the Shared and Stdio groups are allowed, as needed
+// by the Go runtime and common libraries, along with any additional paths.
+//
+// Jail always uses Mandatory safety; if the process cannot be locked an
+// error is returned and the Root is closed.
+func Jail(dir, mode string, paths ...*Path) (*os.Root, error) {
+	if !IsProperMode(mode) {
+		return nil, ErrImproperMode
+	}
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := root.Open(".")
+	if err != nil {
+		return nil, errors.Join(err, root.Close())
+	}
+	defer func() { _ = f.Close() }()
+
+	rules := append([]*Path{Shared(), Stdio(), FDDir(f, mode)}, paths...)
+	if err = New(rules...).Lock(Mandatory); err != nil {
+		return nil, errors.Join(err, root.Close())
+	}
+
+	return root, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestJail(t *testing.T) {
+	cases := map[string]func(){
+		"read_inside": func() {
+			d := filepath.Join(os.TempDir(), randomDir())
+			err := os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+			writeFile(t, filepath.Join(d, "a.txt"), "a", 0o644)
+
+			root, err := Jail(d, "r")
+			must.NoError(t, err)
+			defer func() { _ = root.Close() }()
+
+			// through the root
+			b, err := root.ReadFile("a.txt")
+			must.NoError(t, err)
+			must.Eq(t, "a", string(b))
+
+			// not through the root, but still inside dir
+			_, err = os.ReadFile(filepath.Join(d, "a.txt"))
+			must.NoError(t, err)
+
+			// outside of dir is enforced by the kernel
+			_, err = os.ReadFile("/etc/passwd")
+			must.ErrorIs(t, err, os.ErrPermission)
+		},
+		"write_denied": func() {
+			d := filepath.Join(os.TempDir(), randomDir())
+			err := os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+
+			root, err := Jail(d, "r")
+			must.NoError(t, err)
+			defer func() { _ = root.Close() }()
+
+			err = root.WriteFile("b.txt", []byte("b"), 0o644)
+			must.ErrorIs(t, err, os.ErrPermission)
+		},
+		"extra_paths": func() {
+			d := filepath.Join(os.TempDir(), randomDir())
+			err := os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+
+			root, err := Jail(d, "rwc", File("/etc/passwd", "r"))
+			must.NoError(t, err)
+			defer func() { _ = root.Close() }()
+
+			err = root.WriteFile("b.txt", []byte("b"), 0o644)
+			must.NoError(t, err)
+			_, err = os.ReadFile("/etc/passwd")
+			must.NoError(t, err)
+		},
+		"missing_dir": func() {
+			_, err := Jail(filepath.Join(os.TempDir(), randomDir()), "r")
+			must.ErrorIs(t, err, os.ErrNotExist)
+		},
+		"improper_mode": func() {
+			_, err := Jail(os.TempDir(), "z")
+			must.ErrorIs(t, err, ErrImproperMode)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestJail", cases)
+}
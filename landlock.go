@@ -41,5 +41,26 @@ const (
 // A Locker is an interface over the Kernel landlock LSM feature.
 type Locker interface {
 	fmt.Stringer
+
+	// Lock restricts the process to the paths of the Locker.
 	Lock(s Safety) error
+
+	// Check validates the Locker by doing everything Lock would do,
+	// except for restricting the process. The same errors Lock would
+	// return are returned, which makes Check suitable for rejecting
+	// bad policies long before the process is ready to be locked.
+	Check(s Safety) error
+
+	// LockThread restricts only the OS thread of the calling goroutine
+	// to the paths of the Locker, rather than the whole process.
+	//
+	// The calling goroutine is locked to its OS thread, and must never
+	// call runtime.UnlockOSThread; when the goroutine exits the thread
+	// is terminated rather than being returned to the scheduler. Other
+	// goroutines, including those started by the calling goroutine, run
+	// on other threads and are not restricted.
+	//
+	// LockThread is intended for specialised workers and tests. Most
+	// programs should use Lock.
+	LockThread(s Safety) error
 }
@@ -13,12 +13,17 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// psxReadsTasks is whether psx reads the task directory of the process to
+// find every thread, after the calling thread is already restricted.
+const psxReadsTasks = true
+
 func addProcTaskRule(fd int) error {
 	procTaskPath := fmt.Sprintf("/proc/%d/task", os.Getpid())
 	fd2, err := syscall.Open(procTaskPath, unix.O_PATH|unix.O_CLOEXEC, 0)
 	if err != nil {
 		return err
 	}
+	defer func() { _ = syscall.Close(fd2) }()
 	ba := beneathAttr{allowedAccess: uint64(fsReadDir)}
 	ba.parentFd = fd2
 	return add(fd, &ba)
@@ -26,6 +26,14 @@ func New(...*Path) Locker {
 }
 
 func (l *locker) Lock(s Safety) error {
+	return l.Check(s)
+}
+
+func (l *locker) LockThread(s Safety) error {
+	return l.Check(s)
+}
+
+func (l *locker) Check(s Safety) error {
 	switch s {
 	case OnlyAvailable:
 		return nil
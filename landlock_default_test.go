@@ -31,6 +31,24 @@ func TestLocker_Lock_OnlySupported(t *testing.T) {
 	must.NoError(t, err)
 }
 
+func TestLocker_Check_Mandatory(t *testing.T) {
+	l := New()
+	err := l.Check(Mandatory)
+	must.Error(t, err)
+}
+
+func TestLocker_Check_Try(t *testing.T) {
+	l := New()
+	err := l.Check(Try)
+	must.NoError(t, err)
+}
+
+func TestLocker_LockThread_Mandatory(t *testing.T) {
+	l := New()
+	err := l.LockThread(Mandatory)
+	must.Error(t, err)
+}
+
 func TestLocker_String(t *testing.T) {
 	l := New()
 	s := l.String()
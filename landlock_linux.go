@@ -8,6 +8,11 @@ package landlock
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
 	"syscall"
 
 	"github.com/hashicorp/go-set/v3"
@@ -17,39 +22,232 @@ import (
 var (
 	ErrLandlockNotAvailable = errors.New("landlock not available")
 	ErrLandlockFailedToLock = errors.New("landlock failed to lock")
+
+	// ErrPathChanged indicates a pinned path no longer refers to the
+	// same file it did when the Path was created
+	ErrPathChanged = errors.New("pinned path changed")
+
+	// ErrForeignLocker indicates a Locker implementation other than the
+	// one created by New, which cannot be used to restrict other processes
+	ErrForeignLocker = errors.New("locker not created by New")
 )
 
 type locker struct {
-	paths *set.HashSet[*Path, string]
+	paths    *set.HashSet[*Path, string]
+	hardened bool
+	base     string
+	audit    *audit
+	search   *string         // directories searched for programs, if not PATH
+	root     string          // root directory of the built-in groups
+	groups   []string        // names of the groups given to New
+	explicit map[string]bool // hashes of the paths not from groups
+	err      error           // deferred until locking
+
+	mu     sync.Mutex
+	locked bool
 }
 
 // New creates a Locker that allows the given paths and permissions.
+//
+// Relative paths are resolved against the working directory at the time
+// New is called, or against the directory set by the BaseDir option.
 func New(paths ...*Path) Locker {
+	l := &locker{root: "/", explicit: make(map[string]bool)}
+	for _, path := range paths {
+		if path.opt != nil {
+			path.opt(l)
+		}
+	}
+
+	var g *groups // only if a group is used
 	s := set.NewHashSet[*Path](10)
 	for _, path := range paths {
 		switch path.mode {
-		case modeShared:
-			s.InsertSlice(shared)
-		case modeStdio:
-			s.InsertSlice(stdio)
-		case modeTTY:
-			s.InsertSlice(tty)
-		case modeTmp:
-			s.InsertSlice(tmp)
-		case modeVMInfo:
-			s.InsertSlice(vminfo)
-		case modeDNS:
-			s.InsertSlice(dns)
-		case modeCerts:
-			s.InsertSlice(certs)
+		case modeOption:
+			continue
+		case modeGroup:
+			if g == nil {
+				g = groupsOf(l.root)
+			}
+			l.groups = append(l.groups, path.path)
+			l.group(s, g, path.path)
+		case modeDerived:
+			for _, p := range l.derive(path) {
+				merge(s, []*Path{p})
+				l.explicit[p.Hash()] = true
+			}
+		default:
+			p := l.prepare(path)
+			merge(s, []*Path{p})
+			l.explicit[p.Hash()] = true
+		}
+	}
+	l.paths = s
+	return l
+}
+
+// group inserts the paths of the named group into s, including those of
+// any groups it refers to.
+func (l *locker) group(s *set.HashSet[*Path, string], g *groups, name string) {
+	if paths, exists := g.named(name); exists {
+		merge(s, paths)
+		return
+	}
+
+	paths, exists := registeredGroup(name)
+	if !exists {
+		l.err = errors.Join(l.err, fmt.Errorf("%w: %s", ErrGroupNotFound, name))
+		return
+	}
+	for _, path := range paths {
+		switch path.mode {
+		case modeGroup:
+			l.group(s, g, path.path)
+		case modeDerived:
+			merge(s, l.derive(path))
 		default:
-			s.Insert(path)
+			merge(s, []*Path{l.prepare(path)})
 		}
 	}
-	return &locker{paths: s}
 }
 
+// merge inserts paths into s. A path already in s is given the combined
+// mode of both, as groups may overlap (e.g. a store directory needed by
+// several groups) and a path may be given both explicitly and by a group.
+// A path given explicitly is no longer builtin, and keeps its own options.
+func merge(s *set.HashSet[*Path, string], paths []*Path) {
+	for _, p := range paths {
+		var existing *Path
+		for q := range s.Items() {
+			if q.Hash() == p.Hash() && q.dir == p.dir {
+				existing = q
+				break
+			}
+		}
+		if existing == nil {
+			s.Insert(p)
+			continue
+		}
+		base := existing
+		if existing.builtin && !p.builtin {
+			base = p
+		}
+		c := *base
+		c.mode = unionMode(existing.mode, p.mode)
+		c.builtin = existing.builtin && p.builtin
+		s.Remove(existing)
+		s.Insert(&c)
+	}
+}
+
+// derive returns the paths computed by the derived Path p, configured for
+// l. An error computing the paths is deferred until l is locked.
+func (l *locker) derive(p *Path) []*Path {
+	paths, err := p.expand(l)
+	if err != nil {
+		l.err = errors.Join(l.err, err)
+		return nil
+	}
+	result := make([]*Path, 0, len(paths))
+	for _, path := range paths {
+		result = append(result, l.prepare(path))
+	}
+	return result
+}
+
+// Hardened creates a Path which configures the Locker to open each of the
+// explicitly given paths with openat2, refusing to resolve symbolic links
+// or magic links in any component of the path. This prevents a path from
+// being swapped for a symlink to somewhere else in between creating the
+// Locker and calling Lock.
+//
+// Paths included by the built-in groups (e.g. Shared) are not hardened,
+// as they commonly traverse symlinks such as /lib -> /usr/lib.
+func Hardened() *Path {
+	return &Path{
+		mode: modeOption,
+		opt: func(l *locker) {
+			l.hardened = true
+		},
+	}
+}
+
+// BaseDir creates a Path which configures the Locker to resolve relative
+// paths against dir, rather than the working directory at the time New is
+// called.
+func BaseDir(dir string) *Path {
+	return &Path{
+		mode: modeOption,
+		opt: func(l *locker) {
+			l.base = dir
+		},
+	}
+}
+
+// prepare returns a copy of p configured according to the options of l,
+// with the path made absolute.
+func (l *locker) prepare(p *Path) *Path {
+	c := *p
+	c.hardened = c.hardened || l.hardened
+	if c.file == nil {
+		c.path = absolute(l.base, c.path)
+	}
+	return &c
+}
+
+// absolute returns path resolved against base, or against the working
+// directory if base is empty or itself relative.
+func absolute(base, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// Lock restricts the process to the paths of l, adding a landlock layer.
+//
+// Locking the same Locker more than once, including concurrently, adds
+// only one layer.
 func (l *locker) Lock(s Safety) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locked {
+		return nil
+	}
+
+	return l.apply(s, func() error {
+		if err := l.lock(); err != nil {
+			return err
+		}
+		l.locked = true
+		record(l.paths.Slice())
+		return nil
+	})
+}
+
+// isLocked returns whether l has restricted the process.
+func (l *locker) isLocked() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.locked
+}
+
+func (l *locker) Check(s Safety) error {
+	return l.apply(s, l.check)
+}
+
+// LockThread restricts only the OS thread of the calling goroutine, which
+// remains locked to the thread. Unlike Lock, psx is not used and the layer
+// is not tracked by Layers.
+func (l *locker) LockThread(s Safety) error {
+	return l.apply(s, l.lockThread)
+}
+
+func (l *locker) apply(s Safety, f func() error) error {
 	if !available {
 		if s == Try || s == OnlyAvailable {
 			return nil
@@ -57,57 +255,225 @@ func (l *locker) Lock(s Safety) error {
 		return ErrLandlockNotAvailable
 	}
 
-	if err := l.lock(); err != nil && s != Try {
+	if err := f(); err != nil && s != Try {
 		return errors.Join(ErrLandlockFailedToLock, err)
 	}
 
 	return nil
 }
 
+// String lists the groups of l by name, followed by the other paths of l.
 func (l *locker) String() string {
-	return l.paths.StringFunc(func(p *Path) string {
-		return fmt.Sprintf("%s:%s", p.mode, p.path)
-	})
+	items := make([]string, 0, len(l.groups)+len(l.explicit))
+	for _, name := range l.groups {
+		items = append(items, fmt.Sprintf("%s:%s", modeGroup, name))
+	}
+	for p := range l.paths.Items() {
+		if l.explicit[p.Hash()] {
+			items = append(items, fmt.Sprintf("%s:%s", p.mode, p.path))
+		}
+	}
+	slices.Sort(items)
+	return fmt.Sprintf("%s", slices.Compact(items))
 }
 
 func (l *locker) lock() error {
-	c := capabilities()
-	ra := rulesetAttr{handleAccessFS: uint64(c)}
+	fd, err := l.build()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = syscall.Close(fd) }()
 
-	fd, err := ruleset(&ra)
+	if err = l.inspect(fd, true); err != nil {
+		return err
+	}
+
+	return enforce(fd)
+}
+
+func (l *locker) lockThread() error {
+	fd, err := l.build()
 	if err != nil {
 		return err
 	}
+	defer func() { _ = syscall.Close(fd) }()
 
-	list := l.paths.Slice()
-	for _, path := range list {
-		if err = l.lockOne(path, fd); err != nil {
+	// never unlocked, so the thread is terminated when the goroutine exits
+	// instead of being reused for other goroutines
+	runtime.LockOSThread()
+
+	return enforceThread(fd)
+}
+
+// enforce restricts the process to the ruleset of fd.
+func enforce(fd int) error {
+	return enforceWith(fd, addProcTaskRule)
+}
+
+// enforceShared restricts the process to the ruleset of fd like enforce,
+// but leaves the ruleset unmodified, as it may be shared with other
+// processes (e.g. a ruleset inherited from the parent process). Without the
+// thread synchronising flag, this is only possible if psx does not need to
+// read the task directory of the process.
+func enforceShared(fd int) error {
+	return enforceWith(fd, func(int) error {
+		if psxReadsTasks {
+			return ErrNoThreadSync
+		}
+		return nil
+	})
+}
+
+// enforceWith restricts the process to the ruleset of fd, first calling
+// prepare on the ruleset if it is to be applied using psx.
+func enforceWith(fd int, prepare func(int) error) error {
+	if tsync.Load() {
+		err := enforceSync(fd)
+		if !errors.Is(err, unix.EINVAL) {
 			return err
 		}
+		// the flag is not supported after all
+		tsync.Store(false)
 	}
 
-	if err = prctl(); err != nil {
+	if err := prepare(fd); err != nil {
 		return err
 	}
 
-	if err = addProcTaskRule(fd); err != nil {
+	if err := prctl(); err != nil {
 		return err
 	}
 
-	if err = restrict(fd); err != nil {
+	if err := restrict(fd); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// enforceSync restricts every thread of the process to the ruleset of fd
+// using the thread synchronising flag, without the need for psx. The kernel
+// propagates no_new_privs of the calling thread along with the restriction.
+func enforceSync(fd int) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := prctlThread(); err != nil {
+		return err
+	}
+	return restrictSync(fd)
+}
+
+// enforceThread restricts the current OS thread to the ruleset of fd,
+// leaving the ruleset unmodified. The caller must have locked the calling
+// goroutine to its OS thread.
+func enforceThread(fd int) error {
+	if err := prctlThread(); err != nil {
+		return err
+	}
+	return restrictThread(fd)
+}
+
+func (l *locker) check() error {
+	fd, err := l.build()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = syscall.Close(fd) }()
+
+	return l.inspect(fd, false)
+}
+
+// build creates a landlock ruleset containing a rule for each path of l,
+// returning the file descriptor of the ruleset.
+func (l *locker) build() (int, error) {
+	if l.err != nil {
+		return -1, l.err
+	}
+
+	c := capabilities()
+	ra := rulesetAttr{handleAccessFS: uint64(c)}
+
+	fd, err := ruleset(&ra)
+	if err != nil {
+		return -1, err
+	}
+
+	list := l.paths.Slice()
+	for _, path := range list {
+		if err = l.lockOne(path, fd); err != nil {
+			_ = syscall.Close(fd)
+			return -1, err
+		}
+	}
+
+	return fd, nil
+}
+
 func (l *locker) lockOne(p *Path, fd int) error {
 	allow := p.access()
 	ba := beneathAttr{allowedAccess: uint64(allow)}
-	fd2, err := syscall.Open(p.path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	fd2, err := open(p)
 	if err != nil {
 		return err
 	}
+	if p.file == nil {
+		defer func() { _ = syscall.Close(fd2) }()
+	}
 	ba.parentFd = fd2
-	return add(fd, &ba)
+	err = add(fd, &ba)
+	if errors.Is(err, unix.EBADFD) && p.builtin {
+		// a path of a built-in group may refer to an object without a
+		// filesystem, such as /dev/stdout being a pipe; landlock cannot
+		// restrict access to those so there is nothing to allow
+		return nil
+	}
+	return err
+}
+
+func open(p *Path) (int, error) {
+	if p.file != nil {
+		return descriptor(p)
+	}
+
+	if !p.hardened {
+		fd, err := syscall.Open(p.path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return -1, &os.PathError{Op: "open", Path: p.path, Err: err}
+		}
+		return fd, nil
+	}
+
+	fd, err := openat2(p.path)
+	if err != nil {
+		return -1, &os.PathError{Op: "openat2", Path: p.path, Err: err}
+	}
+
+	if p.pin != nil {
+		id, _, err := identify(fd)
+		switch {
+		case err != nil:
+			_ = syscall.Close(fd)
+			return -1, err
+		case id != *p.pin:
+			_ = syscall.Close(fd)
+			return -1, fmt.Errorf("%w: %s", ErrPathChanged, p.path)
+		}
+	}
+
+	return fd, nil
+}
+
+// descriptor returns the already open file descriptor of p, after checking
+// it refers to the expected kind of file.
+func descriptor(p *Path) (int, error) {
+	fd := int(p.file.Fd())
+	_, dir, err := identify(fd)
+	switch {
+	case err != nil:
+		return -1, &os.PathError{Op: "fstat", Path: p.path, Err: err}
+	case dir != p.dir:
+		return -1, fmt.Errorf("%w: %s", ErrImproperType, p.path)
+	}
+	return fd, nil
 }
@@ -13,10 +13,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sync"
 	"syscall"
 	"testing"
 
 	"github.com/shoenig/test/must"
+	"golang.org/x/sys/unix"
 )
 
 func TestLocker_New(t *testing.T) {
@@ -40,15 +43,229 @@ func TestLocker_String(t *testing.T) {
 
 	t.Run("full", func(t *testing.T) {
 		l := New(
+			BaseDir("/srv/app"),
 			Dir("/home/nobody", "r"),
 			Dir("/opt/bin", "x"),
-			Dir("~/", "rwc"),
+			Dir("data/", "rwc"),
 		)
 		result := l.String()
-		must.Eq(t, "[r:/home/nobody rwc:~/ x:/opt/bin]", result)
+		must.Eq(t, "[r:/home/nobody rwc:/srv/app/data x:/opt/bin]", result)
+	})
+
+	t.Run("relative", func(t *testing.T) {
+		cwd, err := os.Getwd()
+		must.NoError(t, err)
+
+		l := New(Dir("tests/fruits", "r"))
+		result := l.String()
+		must.Eq(t, "[r:"+filepath.Join(cwd, "tests/fruits")+"]", result)
+	})
+
+	t.Run("deduplicate", func(t *testing.T) {
+		l := New(
+			BaseDir("/srv/app"),
+			Dir("./data", "r"),
+			Dir("/srv/app/data/", "r"),
+		)
+		result := l.String()
+		must.Eq(t, "[r:/srv/app/data]", result)
 	})
 }
 
+func TestLocker_Check(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		l := New(Shared(), Stdio(), Dir("tests/fruits", "r"))
+		err := l.Check(Mandatory)
+		must.NoError(t, err)
+
+		// process is not restricted
+		_, err = os.ReadFile("tests/veggies/corn.txt")
+		must.NoError(t, err)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		l := New(File("tests/fruits/missing.txt", "r"))
+		err := l.Check(Mandatory)
+		must.ErrorIs(t, err, ErrLandlockFailedToLock)
+		must.ErrorIs(t, err, os.ErrNotExist)
+		must.ErrorContains(t, err, "tests/fruits/missing.txt")
+	})
+
+	t.Run("pipe", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		must.NoError(t, err)
+		defer func() { _ = r.Close(); _ = w.Close() }()
+
+		// landlock cannot restrict a pipe, which is an error unless
+		// the path is from a built-in group (e.g. Stdio)
+		l := New(FD(r, "r"))
+		err = l.Check(Mandatory)
+		must.ErrorIs(t, err, unix.EBADFD)
+
+		l = New(File(fmt.Sprintf("/proc/self/fd/%d", w.Fd()), "w"))
+		err = l.Check(Mandatory)
+		must.ErrorIs(t, err, unix.EBADFD)
+	})
+
+	t.Run("try", func(t *testing.T) {
+		l := New(File("tests/fruits/missing.txt", "r"))
+		err := l.Check(Try)
+		must.NoError(t, err)
+	})
+}
+
+func TestLocker_layers(t *testing.T) {
+	cases := map[string]func(){
+		"idempotent": func() {
+			l := New(Dir("tests", "r"))
+			var wg sync.WaitGroup
+			errs := make([]error, 4)
+			for i := range errs {
+				wg.Go(func() {
+					errs[i] = l.Lock(Mandatory)
+				})
+			}
+			wg.Wait()
+			for _, err := range errs {
+				must.NoError(t, err)
+			}
+			must.True(t, Restricted())
+			must.Eq(t, 1, Layers())
+		},
+		"stacked": func() {
+			cwd, err := os.Getwd()
+			must.NoError(t, err)
+
+			err = New(Dir("tests", "r")).Lock(Mandatory)
+			must.NoError(t, err)
+			err = New(Dir("tests/fruits", "rw")).Lock(Mandatory)
+			must.NoError(t, err)
+			must.Eq(t, 2, Layers())
+
+			exp := []*Path{Dir(filepath.Join(cwd, "tests/fruits"), "r")}
+			must.Eq(t, exp, Effective())
+		},
+		"check": func() {
+			err := New(Dir("tests", "r")).Check(Mandatory)
+			must.NoError(t, err)
+			must.False(t, Restricted())
+		},
+		"failure": func() {
+			err := New(File("tests/missing.txt", "r")).Lock(Try)
+			must.NoError(t, err)
+			must.False(t, Restricted())
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_layers", cases)
+}
+
+func TestLocker_threads(t *testing.T) {
+	// every thread must be restricted, however that is done
+	allRestricted := func() {
+		var wg sync.WaitGroup
+		errs := make([]error, 16)
+		for i := range errs {
+			wg.Go(func() {
+				runtime.LockOSThread() // likely a different thread
+				_, errs[i] = os.ReadFile("tests/veggies/corn.txt")
+			})
+		}
+		wg.Wait()
+
+		// assert on the test goroutine, as FailNow must not be called
+		// from other goroutines
+		for _, err := range errs {
+			must.ErrorIs(t, err, os.ErrPermission)
+		}
+	}
+
+	cases := map[string]func(){
+		"detected": func() {
+			err := New(Dir("tests/fruits", "r")).Lock(Mandatory)
+			must.NoError(t, err)
+			allRestricted()
+		},
+		"fallback": func() {
+			if version >= 8 {
+				return
+			}
+			// pretend the kernel supports the flag, which it does not
+			tsync.Store(true)
+			err := New(Dir("tests/fruits", "r")).Lock(Mandatory)
+			must.NoError(t, err)
+			must.False(t, ThreadSync())
+			allRestricted()
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_threads", cases)
+}
+
+func TestLocker_LockThread(t *testing.T) {
+	cases := map[string]func(){
+		"this_thread": func() {
+			l := New(Dir("tests/fruits", "r"))
+			err := l.LockThread(Mandatory)
+			must.NoError(t, err)
+
+			_, err = os.ReadFile("tests/fruits/apple.txt")
+			must.NoError(t, err)
+			_, err = os.ReadFile("tests/veggies/corn.txt")
+			must.ErrorIs(t, err, os.ErrPermission)
+			must.False(t, Restricted())
+		},
+		"other_goroutine": func() {
+			l := New(Dir("tests/fruits", "r"))
+			err := l.LockThread(Mandatory)
+			must.NoError(t, err)
+
+			// runs on a different thread, so is unrestricted
+			done := make(chan error)
+			go func() {
+				_, err := os.ReadFile("tests/veggies/corn.txt")
+				done <- err
+			}()
+			must.NoError(t, <-done)
+		},
+		"goroutine_exits": func() {
+			done := make(chan error)
+			go func() {
+				l := New(Dir("tests/fruits", "r"))
+				done <- l.LockThread(Mandatory)
+			}()
+			must.NoError(t, <-done)
+
+			// the restricted thread was terminated, never reused
+			for range 100 {
+				_, err := os.ReadFile("tests/veggies/corn.txt")
+				must.NoError(t, err)
+				runtime.Gosched()
+			}
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_LockThread", cases)
+}
+
 func TestLocker_reads(t *testing.T) {
 	type testCase struct {
 		paths   []string
@@ -199,6 +416,46 @@ func TestLocker_reads(t *testing.T) {
 	forkAndRunEachCase(t, "TestLocker_reads", cases)
 }
 
+func TestLocker_relative(t *testing.T) {
+	cases := map[string]func(){
+		"chdir_after_new": func() {
+			cwd, err := os.Getwd()
+			must.NoError(t, err)
+
+			l := New(Dir("tests/fruits", "r"))
+
+			// relative paths are resolved by New, not Lock
+			err = os.Chdir(os.TempDir())
+			must.NoError(t, err)
+
+			err = l.Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile(filepath.Join(cwd, "tests/fruits/apple.txt"))
+			must.NoError(t, err)
+		},
+		"base_dir": func() {
+			cwd, err := os.Getwd()
+			must.NoError(t, err)
+
+			l := New(BaseDir(filepath.Join(cwd, "tests")), File("veggies/corn.txt", "r"))
+			err = l.Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile("tests/veggies/corn.txt")
+			must.NoError(t, err)
+			_, err = os.ReadFile("tests/veggies/celary.txt")
+			must.Error(t, err)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_relative", cases)
+}
+
 func TestLocker_writes(t *testing.T) {
 	cases := map[string]func(){
 		"none": func() {
@@ -556,6 +813,143 @@ func TestLocker_symlink(t *testing.T) {
 	forkAndRunEachCase(t, "TestLocker_symlink", cases)
 }
 
+func TestLocker_hardened(t *testing.T) {
+	cases := map[string]func(){
+		"symlink_rejected": func() {
+			d := os.TempDir()
+			link := filepath.Join(d, randomDir())
+			err := os.Symlink("/etc", link)
+			must.NoError(t, err)
+
+			l := New(Hardened(), Dir(link, "r"))
+			err = l.Lock(Mandatory)
+			must.ErrorIs(t, err, syscall.ELOOP)
+		},
+		"plain_allowed": func() {
+			f := tmpFile(t, "hello.txt", "hi")
+			l := New(Hardened(), Shared(), File(f, "r"))
+			err := l.Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile(f)
+			must.NoError(t, err)
+		},
+		"pinned_same": func() {
+			d := filepath.Join(os.TempDir(), randomDir())
+			err := os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+			writeFile(t, filepath.Join(d, "a.txt"), "a", 0o644)
+
+			p, err := PinnedDir(d, "r")
+			must.NoError(t, err)
+
+			l := New(p)
+			err = l.Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile(filepath.Join(d, "a.txt"))
+			must.NoError(t, err)
+		},
+		"pinned_swapped": func() {
+			d := filepath.Join(os.TempDir(), randomDir())
+			err := os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+
+			p, err := PinnedDir(d, "r")
+			must.NoError(t, err)
+
+			// swap the directory for a different one of the same name
+			err = os.Rename(d, d+".old")
+			must.NoError(t, err)
+			err = os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+
+			l := New(p)
+			err = l.Lock(Mandatory)
+			must.ErrorIs(t, err, ErrPathChanged)
+		},
+		"pinned_wrong_type": func() {
+			f := tmpFile(t, "hello.txt", "hi")
+			_, err := PinnedDir(f, "r")
+			must.ErrorIs(t, err, ErrImproperType)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_hardened", cases)
+}
+
+func TestLocker_fd(t *testing.T) {
+	cases := map[string]func(){
+		"dir_o_path": func() {
+			d := filepath.Join(os.TempDir(), randomDir())
+			err := os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+			writeFile(t, filepath.Join(d, "a.txt"), "a", 0o644)
+
+			fd, err := syscall.Open(d, unix.O_PATH|unix.O_CLOEXEC, 0)
+			must.NoError(t, err)
+			f := os.NewFile(uintptr(fd), d)
+
+			l := New(FDDir(f, "r"))
+			err = l.Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile(filepath.Join(d, "a.txt"))
+			must.NoError(t, err)
+		},
+		"dir_moved": func() {
+			d := filepath.Join(os.TempDir(), randomDir())
+			err := os.Mkdir(d, 0o755)
+			must.NoError(t, err)
+			writeFile(t, filepath.Join(d, "a.txt"), "a", 0o644)
+
+			f, err := os.Open(d)
+			must.NoError(t, err)
+
+			// the rule follows the directory, not the name
+			moved := d + ".moved"
+			err = os.Rename(d, moved)
+			must.NoError(t, err)
+
+			l := New(FDDir(f, "r"))
+			err = l.Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile(filepath.Join(moved, "a.txt"))
+			must.NoError(t, err)
+		},
+		"file": func() {
+			p := tmpFile(t, "hello.txt", "hi")
+			f, err := os.Open(p)
+			must.NoError(t, err)
+
+			l := New(FD(f, "r"))
+			err = l.Lock(Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile(p)
+			must.NoError(t, err)
+		},
+		"wrong_kind": func() {
+			f, err := os.Open(os.TempDir())
+			must.NoError(t, err)
+
+			l := New(FD(f, "r"))
+			err = l.Lock(Mandatory)
+			must.ErrorIs(t, err, ErrImproperType)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_fd", cases)
+}
+
 func TestLocker_hardlink(t *testing.T) {
 	cases := map[string]func(){
 		"read_existing_link": func() {
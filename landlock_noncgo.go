@@ -5,6 +5,10 @@
 
 package landlock
 
+// psxReadsTasks is whether psx reads the task directory of the process to
+// find every thread, after the calling thread is already restricted.
+const psxReadsTasks = false
+
 func addProcTaskRule(_ int) error {
 	return nil
 }
@@ -0,0 +1,167 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ldConfPath is the configuration of library directories read by ldconfig
+// to build the cache.
+const ldConfPath = "/etc/ld.so.conf"
+
+// ldCachePath is the cache of shared library locations maintained by
+// ldconfig, read by the dynamic loader.
+const ldCachePath = "/etc/ld.so.cache"
+
+// ldCacheMagic begins the header of the cache format written by glibc 2.32
+// and later, which older versions write after the legacy format.
+var ldCacheMagic = []byte("glibc-ld.so.cache1.1")
+
+const (
+	ldCacheHeaderSize = 48 // magic, nlibs, len_strings, flags, extension_offset, unused
+	ldCacheEntrySize  = 24 // flags, key, value, osversion, hwcap
+)
+
+// ErrLDCacheFormat indicates a shared library cache file of an unknown or
+// corrupt format
+var ErrLDCacheFormat = errors.New("unrecognized ld.so.cache format")
+
+// ldCache maps the name of each shared library in the cache to the paths
+// of the library, of which there may be one per architecture or hardware
+// capability.
+type ldCache map[string][]string
+
+// readLDCache parses the shared library cache file at path.
+func readLDCache(path string) (ldCache, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseLDCache(b)
+}
+
+func parseLDCache(b []byte) (ldCache, error) {
+	// offsets of strings are relative to the start of the new format
+	start := bytes.Index(b, ldCacheMagic)
+	if start < 0 || len(b)-start < ldCacheHeaderSize {
+		return nil, ErrLDCacheFormat
+	}
+	b = b[start:]
+
+	n := int(binary.NativeEndian.Uint32(b[20:]))
+	if n > (len(b)-ldCacheHeaderSize)/ldCacheEntrySize {
+		return nil, fmt.Errorf("%w: %d entries", ErrLDCacheFormat, n)
+	}
+
+	cache := make(ldCache, n)
+	for i := range n {
+		entry := b[ldCacheHeaderSize+i*ldCacheEntrySize:]
+		key, ok1 := cString(b, binary.NativeEndian.Uint32(entry[4:]))
+		value, ok2 := cString(b, binary.NativeEndian.Uint32(entry[8:]))
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%w: entry %d", ErrLDCacheFormat, i)
+		}
+		cache[key] = append(cache[key], value)
+	}
+	return cache, nil
+}
+
+// cString returns the NUL terminated string at offset of b.
+func cString(b []byte, offset uint32) (string, bool) {
+	if int(offset) >= len(b) {
+		return "", false
+	}
+	s := b[offset:]
+	end := bytes.IndexByte(s, 0)
+	if end < 0 {
+		return "", false
+	}
+	return string(s[:end]), true
+}
+
+// ldConfDirs returns the library directories configured by the ld.so.conf
+// file at path below root, following include directives, in the order
+// listed. Absolute include patterns are resolved below root as well.
+func ldConfDirs(root, path string) []string {
+	var dirs []string
+	visited := make(map[string]bool)
+
+	var read func(path string)
+	read = func(path string) {
+		if visited[path] {
+			return
+		}
+		visited[path] = true
+
+		f, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer func() { _ = f.Close() }()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			fields := strings.FieldsFunc(line, func(r rune) bool {
+				return r == ' ' || r == '\t' || r == ':' || r == ','
+			})
+			switch {
+			case len(fields) == 0:
+				continue
+			case fields[0] == "hwcap":
+				continue
+			case fields[0] == "include":
+				for _, pattern := range fields[1:] {
+					if filepath.IsAbs(pattern) {
+						pattern = filepath.Join(root, pattern)
+					} else {
+						pattern = filepath.Join(filepath.Dir(path), pattern)
+					}
+					matches, _ := filepath.Glob(pattern)
+					for _, match := range matches {
+						read(match)
+					}
+				}
+			default:
+				for _, dir := range fields {
+					// libc5 era entries may be suffixed by =type
+					dir, _, _ = strings.Cut(dir, "=")
+					if filepath.IsAbs(dir) {
+						dirs = append(dirs, filepath.Clean(dir))
+					}
+				}
+			}
+		}
+	}
+
+	read(filepath.Join(root, path))
+	return dirs
+}
+
+// libraryDirs returns the directories containing shared libraries below
+// root, as configured by the ld.so.conf file at conf and as listed in the
+// cache file at cache, both below root.
+func libraryDirs(root, conf, cache string) []string {
+	dirs := ldConfDirs(root, conf)
+	if c, err := readLDCache(filepath.Join(root, cache)); err == nil {
+		for _, paths := range c {
+			for _, path := range paths {
+				dirs = append(dirs, filepath.Dir(path))
+			}
+		}
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+// makeLDCache encodes entries of name and path in the cache format.
+func makeLDCache(entries [][2]string) []byte {
+	strings := ldCacheHeaderSize + len(entries)*ldCacheEntrySize
+	b := make([]byte, strings)
+	copy(b, ldCacheMagic)
+	binary.NativeEndian.PutUint32(b[20:], uint32(len(entries)))
+	for i, entry := range entries {
+		e := ldCacheHeaderSize + i*ldCacheEntrySize
+		binary.NativeEndian.PutUint32(b[e+4:], uint32(len(b)))
+		b = append(append(b, entry[0]...), 0)
+		binary.NativeEndian.PutUint32(b[e+8:], uint32(len(b)))
+		b = append(append(b, entry[1]...), 0)
+	}
+	return b
+}
+
+func Test_parseLDCache(t *testing.T) {
+	t.Run("entries", func(t *testing.T) {
+		b := makeLDCache([][2]string{
+			{"libz.so.1", "/usr/lib/x86_64-linux-gnu/libz.so.1"},
+			{"libc.so.6", "/usr/lib/x86_64-linux-gnu/libc.so.6"},
+			{"libc.so.6", "/usr/lib/i386-linux-gnu/libc.so.6"},
+		})
+		cache, err := parseLDCache(b)
+		must.NoError(t, err)
+		must.Eq(t, ldCache{
+			"libz.so.1": {"/usr/lib/x86_64-linux-gnu/libz.so.1"},
+			"libc.so.6": {"/usr/lib/x86_64-linux-gnu/libc.so.6", "/usr/lib/i386-linux-gnu/libc.so.6"},
+		}, cache)
+	})
+
+	t.Run("legacy_prefix", func(t *testing.T) {
+		b := append([]byte("ld.so-1.7.0\x00\x00\x00\x00\x00"), makeLDCache([][2]string{
+			{"libz.so.1", "/lib/libz.so.1"},
+		})...)
+		cache, err := parseLDCache(b)
+		must.NoError(t, err)
+		must.Eq(t, []string{"/lib/libz.so.1"}, cache["libz.so.1"])
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := parseLDCache([]byte("ld.so-1.7.0"))
+		must.ErrorIs(t, err, ErrLDCacheFormat)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		b := makeLDCache([][2]string{{"libz.so.1", "/lib/libz.so.1"}})
+		_, err := parseLDCache(b[:len(b)-4])
+		must.ErrorIs(t, err, ErrLDCacheFormat)
+	})
+}
+
+func Test_readLDCache(t *testing.T) {
+	cache, err := readLDCache(ldCachePath)
+	if err != nil {
+		t.Skipf("no usable %s: %v", ldCachePath, err)
+	}
+	must.SliceNotEmpty(t, cache["libc.so.6"])
+}
+
+func Test_ldConfDirs(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "ld.so.conf")
+	must.NoError(t, os.Mkdir(filepath.Join(dir, "ld.so.conf.d"), 0o755))
+
+	writeFile(t, conf, strings.Join([]string{
+		"# libraries",
+		"/usr/local/lib",
+		"include ld.so.conf.d/*.conf",
+		"hwcap 0 nosegneg",
+		"/opt/old/lib=libc5 /opt/a/lib:/opt/b/lib/",
+		"relative/lib",
+	}, "\n"), 0o644)
+	writeFile(t, filepath.Join(dir, "ld.so.conf.d", "1-vendor.conf"),
+		"/opt/vendor/lib # vendor\ninclude /ld.so.conf\n", 0o644)
+	writeFile(t, filepath.Join(dir, "ld.so.conf.d", "2-multiarch.conf"),
+		"/usr/lib/x86_64-linux-gnu\n", 0o644)
+	writeFile(t, filepath.Join(dir, "ld.so.conf.d", "ignored.txt"),
+		"/ignored\n", 0o644)
+
+	dirs := ldConfDirs(dir, "/ld.so.conf")
+	must.Eq(t, []string{
+		"/usr/local/lib",
+		"/opt/vendor/lib",
+		"/usr/lib/x86_64-linux-gnu",
+		"/opt/old/lib",
+		"/opt/a/lib",
+		"/opt/b/lib",
+	}, dirs)
+
+	must.SliceEmpty(t, ldConfDirs(dir, "/missing.conf"))
+}
+
+func Test_libraryDirs(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "ld.so.conf")
+	cache := filepath.Join(dir, "ld.so.cache")
+	writeFile(t, conf, "/opt/vendor/lib\n/usr/local/lib\n", 0o644)
+	writeFile(t, cache, string(makeLDCache([][2]string{
+		{"libvendor.so.1", "/opt/vendor/lib/libvendor.so.1"},
+		{"libc.so.6", "/usr/lib/x86_64-linux-gnu/libc.so.6"},
+		{"libz.so.1", "/usr/lib/x86_64-linux-gnu/libz.so.1"},
+	})), 0o644)
+
+	dirs := libraryDirs(dir, "/ld.so.conf", "/ld.so.cache")
+	must.Eq(t, []string{
+		"/opt/vendor/lib",
+		"/usr/lib/x86_64-linux-gnu",
+		"/usr/local/lib",
+	}, dirs)
+}
+
+func TestShared_host(t *testing.T) {
+	// every existing library directory of the host is covered by Shared
+	group := New(Shared()).(*locker).paths.Slice()
+	for _, dir := range libraryDirs("/", ldConfPath, ldCachePath) {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		p := Dir(dir, "rx")
+		must.True(t, slices.ContainsFunc(group, func(q *Path) bool {
+			return covers(q, p)
+		}), must.Sprint("not covered", dir))
+	}
+}
+
+func TestLibraryPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LD_LIBRARY_PATH", dir+":/does/not/exist:relative/lib:")
+	l := New(LibraryPath()).(*locker)
+	must.Eq(t, []*Path{Dir(dir, "rx")}, l.paths.Slice())
+	must.NoError(t, l.Check(Mandatory))
+
+	t.Setenv("LD_LIBRARY_PATH", "")
+	must.Eq(t, "[]", New(LibraryPath()).String())
+}
@@ -6,6 +6,9 @@ package landlock
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -20,10 +23,25 @@ var (
 	ErrImproperPath = errors.New("improper path")
 )
 
+// modeGroup is the mode of a Path referring to a group of paths by name.
+const modeGroup = "g"
+
 type Path struct {
-	mode string // any of rwxc
-	path string // filepath of interest
-	dir  bool   // true iff path represents a directory
+	mode     string                         // any of rwxc
+	path     string                         // filepath of interest
+	dir      bool                           // true iff path represents a directory
+	hardened bool                           // true iff path must be opened without following symlinks
+	pin      *inode                         // expected device and inode of path, if pinned
+	file     *os.File                       // open descriptor of path, if created from a file
+	opt      func(*locker)                  // configures the locker, if path is an option
+	expand   func(*locker) ([]*Path, error) // computes the paths, if path is derived
+	builtin  bool                           // true iff path is from a built-in group
+}
+
+// inode identifies a file by its device and inode numbers.
+type inode struct {
+	dev uint64
+	ino uint64
 }
 
 // Equal returns true if p is equal to o in terms
@@ -44,12 +62,19 @@ func (p *Path) Equal(o *Path) bool {
 	}
 }
 
-// Hash returns the path element of p.
+// Hash returns the path element of p, or the descriptor of p if p was
+// created from an open file.
 func (p *Path) Hash() string {
+	if p.file != nil {
+		return "fd:" + strconv.Itoa(int(p.file.Fd()))
+	}
 	return p.path
 }
 
 func (p *Path) String() string {
+	if p.mode == modeGroup {
+		return fmt.Sprintf("(group:%s)", p.path)
+	}
 	kind := ifelse(p.dir, "dir", "file")
 	return fmt.Sprintf("(%s:%s:%s)", p.mode, kind, p.path)
 }
@@ -68,6 +93,40 @@ func Dir(path, mode string) *Path {
 	return newPath(path, mode, true)
 }
 
+// FD creates a Path given an open file and mode, associated with a file.
+//
+// Unlike File, the path is not opened again at lock time; the rule applies
+// to whichever file f refers to, including files which are only reachable
+// through f. f may have been opened with O_PATH, and must remain open until
+// the Locker is locked.
+func FD(f *os.File, mode string) *Path {
+	return newFD(f, mode, false)
+}
+
+// FDDir creates a Path given an open directory and mode, associated with a
+// directory.
+//
+// Like FD, the rule applies to whichever directory f refers to. f may have
+// been opened with O_PATH, and must remain open until the Locker is locked.
+func FDDir(f *os.File, mode string) *Path {
+	return newFD(f, mode, true)
+}
+
+func newFD(f *os.File, mode string, dir bool) *Path {
+	if f == nil {
+		panic("improper file")
+	}
+	if !IsProperMode(mode) {
+		panic("improper mode")
+	}
+	return &Path{
+		mode: mode,
+		path: f.Name(),
+		dir:  dir,
+		file: f,
+	}
+}
+
 func newPath(path, mode string, dir bool) *Path {
 	if !IsProperPath(path) {
 		panic("improper path")
@@ -82,6 +141,22 @@ func newPath(path, mode string, dir bool) *Path {
 	}
 }
 
+// Group creates a Path referring to the group of paths with the given
+// name, which is either one of the built-in groups (e.g. "shared", "certs")
+// or a group registered with RegisterGroup.
+//
+// The group is looked up when the Locker is created. A name which does not
+// refer to a group causes Lock to return ErrGroupNotFound.
+func Group(name string) *Path {
+	if !IsProperGroup(name) {
+		panic("improper group")
+	}
+	return &Path{
+		mode: modeGroup,
+		path: name,
+	}
+}
+
 // ParsePath parses s into a Path.
 //
 // s must contain 'd' or 'f' indicating whether the path represents a file
@@ -96,6 +171,9 @@ func newPath(path, mode string, dir bool) *Path {
 //
 // s must be in the form "[kind]:[mode]:[path]"
 //
+// A group of paths is referred to by name with kind 'g' and an empty mode,
+// e.g. "g::certs", as with Group.
+//
 // "d:rw:$HOME" would enable reading and writing to the
 // users home directory.
 //
@@ -114,6 +192,16 @@ func ParsePath(s string) (*Path, error) {
 }
 
 func parsePath(filetype, mode, path string) (*Path, error) {
+	if filetype == modeGroup {
+		switch {
+		case mode != "":
+			return nil, ErrImproperMode
+		case !IsProperGroup(path):
+			return nil, ErrImproperPath
+		}
+		return Group(path), nil
+	}
+
 	switch {
 	case !IsProperType(filetype):
 		return nil, ErrImproperType
@@ -130,6 +218,18 @@ func parsePath(filetype, mode, path string) (*Path, error) {
 	}, nil
 }
 
+// covers returns whether the rule of p applies to the path of q.
+func covers(p, q *Path) bool {
+	if p.path == q.path {
+		return true
+	}
+	if !p.dir {
+		return false
+	}
+	rel, err := filepath.Rel(p.path, q.path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
 func IsProperType(filetype string) bool {
 	return filetype == "d" || filetype == "f"
 }
@@ -151,6 +251,12 @@ func IsProperMode(mode string) bool {
 	return true
 }
 
+// IsProperGroup returns whether name conforms to a valid group name, which
+// must not be empty nor contain a colon or whitespace.
+func IsProperGroup(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ": \t\n")
+}
+
 // IsProperPath returns whether fp conforms to a valid filepath.
 func IsProperPath(path string) bool {
 	return path != ""
@@ -7,6 +7,9 @@ package landlock
 
 import (
 	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
 )
 
 func (p *Path) access() rule {
@@ -31,154 +34,143 @@ func (p *Path) access() rule {
 	return allow
 }
 
-var shared []*Path
-
-var stdio []*Path
-
-var tmp []*Path
-
-var tty []*Path
-
-var vminfo []*Path
-
-var dns []*Path
-
-var certs []*Path
-
-func init() {
-	shared = load([]*Path{
-		File("/dev/null", "rw"),
-		Dir("/lib", "rx"),
-		Dir("/lib64", "rx"),
-		Dir("/usr/lib", "rx"),
-		Dir("/usr/lib64", "rx"),
-		Dir("/usr/libexec", "rx"),
-		Dir("/usr/local/lib", "rx"),
-		Dir("/usr/local/lib64", "rx"),
-		File("/etc/ld.so.conf", "r"),
-		File("/etc/ld.so.cache", "r"),
-		Dir("/etc/ld.so.conf.d", "r"),
-	})
-
-	stdio = load([]*Path{
-		File("/dev/full", "rw"),
-		File("/dev/zero", "r"),
-		File("/dev/fd", "r"),
-		File("/dev/stdin", "rw"),
-		File("/dev/stdout", "rw"),
-		File("/dev/urandom", "r"),
-		Dir("/dev/log", "w"),
-		Dir("/usr/share/locale", "r"),
-		File("/proc/self/cmdline", "r"),
-		File("/usr/share/zoneinfo", "r"),
-		File("/usr/share/common-licenses", "r"),
-		File("/proc/sys/kernel/ngroups_max", "r"),
-		File("/proc/sys/kernel/cap_last_cap", "r"),
-		File("/proc/sys/vm/overcommit_memory", "r"),
-	})
-
-	tty = load([]*Path{
-		File("/dev/tty", "rw"),
-		File("/dev/console", "rw"),
-		File("/etc/terminfo", "r"),
-		Dir("/usr/lib/terminfo", "r"),
-		Dir("/usr/share/terminfo", "r"),
-	})
-
-	tmp = load([]*Path{
-		Dir("/tmp", "rwc"),
-	})
-
-	vminfo = load([]*Path{
-		File("/proc/stat", "r"),
-		File("/proc/meminfo", "r"),
-		File("/proc/cpuinfo", "r"),
-		File("/proc/diskstats", "r"),
-		File("/proc/self/maps", "r"),
-		File("/proc/sys/kernel/version", "r"),
-		File("/sys/devices/system/cpu", "r"),
-	})
-
-	dns = load([]*Path{
-		File("/etc/hosts", "r"),
-		File("/hostname", "r"),
-		File("/etc/services", "r"),
-		File("/etc/protocols", "r"),
-		File("/etc/resolv.conf", "r"),
-	})
-
-	// https://cs.opensource.google/go/go/+/refs/tags/go1.19.3:src/crypto/x509/root_linux.go
-	certs = load([]*Path{
-		Dir("/etc/ssl/certs", "r"),                                     // SLES, Debian
-		Dir("/etc/pki/tls/certs", "r"),                                 // Fedora / RHEL
-		Dir("/sys/etc/security/cacerts", "r"),                          // Android
-		File("/etc/ssl/ca-bundle.pem", "r"),                            // OpenSUSE
-		File("/etc/pki/tls/cacert.pem", "r"),                           // OpenELEC
-		File("/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", "r"), // RHEL 7
-		File("/etc/ssl/cert.pem", "r"),                                 // Alpine
-	})
-}
-
 const (
-	modeShared = "1"
-	modeStdio  = "2"
-	modeTTY    = "3"
-	modeTmp    = "4"
-	modeVMInfo = "5"
-	modeDNS    = "6"
-	modeCerts  = "7"
+	modeOption  = "8"
+	modeDerived = "9"
 )
 
-func load(paths []*Path) []*Path {
-	result := make([]*Path, 0, len(paths))
-	for _, p := range paths {
-		if _, err := os.Stat(p.path); err == nil {
-			result = append(result, p)
-		}
+// PinnedFile creates a File Path, recording the device and inode of the
+// file at path as it is now. Locking will fail with ErrPathChanged if path
+// refers to a different file by the time Lock is called.
+//
+// Like paths of a Hardened Locker, a pinned path is opened without
+// following symbolic links, both now and when locking.
+func PinnedFile(path, mode string) (*Path, error) {
+	return pin(newPath(path, mode, false))
+}
+
+// PinnedDir creates a Dir Path, recording the device and inode of the
+// directory at path as it is now. Locking will fail with ErrPathChanged if
+// path refers to a different directory by the time Lock is called.
+//
+// Like paths of a Hardened Locker, a pinned path is opened without
+// following symbolic links, both now and when locking.
+func PinnedDir(path, mode string) (*Path, error) {
+	return pin(newPath(path, mode, true))
+}
+
+func pin(p *Path) (*Path, error) {
+	p.path = absolute("", p.path)
+	fd, err := openat2(p.path)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat2", Path: p.path, Err: err}
 	}
-	return result
+	defer func() { _ = unix.Close(fd) }()
+
+	id, dir, err := identify(fd)
+	switch {
+	case err != nil:
+		return nil, err
+	case dir != p.dir:
+		return nil, ErrImproperType
+	}
+
+	p.hardened = true
+	p.pin = &id
+	return p, nil
 }
 
 // Shared creates a Path representing the common files and directories
-// needed for dynamic shared object files.
+// needed for dynamic shared object files, including the directories
+// configured by /etc/ld.so.conf and those of the libraries listed in
+// /etc/ld.so.cache.
 //
 // Use Shared when allowing the execution of dynamically linked binaries.
 func Shared() *Path {
-	return &Path{mode: modeShared}
+	return Group("shared")
+}
+
+// LibraryPath creates a Path representing the directories listed by the
+// LD_LIBRARY_PATH environment variable, allowed "rx". Directories which do
+// not exist are ignored.
+//
+// The variable is read when the Locker is created. Use LibraryPath along
+// with Shared when executing programs relying on LD_LIBRARY_PATH.
+func LibraryPath() *Path {
+	return &Path{
+		mode: modeDerived,
+		path: "$LD_LIBRARY_PATH",
+		expand: func(*locker) ([]*Path, error) {
+			var paths []*Path
+			for _, dir := range filepath.SplitList(os.Getenv("LD_LIBRARY_PATH")) {
+				if filepath.IsAbs(dir) {
+					paths = append(paths, Dir(dir, "rx"))
+				}
+			}
+			return load("/", paths), nil
+		},
+	}
 }
 
 // Stdio creates a Path representing the common files and directories
 // needed for standard I/O operations.
 func Stdio() *Path {
-	return &Path{mode: modeStdio}
+	return Group("stdio")
 }
 
 // TTY creates a path representing common files needed for terminal
 // operations.
 func TTY() *Path {
-	return &Path{mode: modeTTY}
+	return Group("tty")
 }
 
 // Tmp creates a Path representing the common files and directories
 // needed for reading and writing to the system tmp space.
 func Tmp() *Path {
-	return &Path{mode: modeTmp}
+	return Group("tmp")
 }
 
 // VMInfo creates a Path representing the common files and directories
 // needed for virtual machines and system introspection.
 func VMInfo() *Path {
-	return &Path{mode: modeVMInfo}
+	return Group("vminfo")
 }
 
 // DNS creates a Path representing the common files needed for DNS
-// related operations.
+// related operations, including the files of the databases nsswitch.conf
+// says are looked up in files, and the directory of the file resolv.conf
+// links to, such as /run/systemd/resolve for systemd-resolved. If that
+// directory is a top-level directory such as /etc or /run, only the file
+// itself is included.
+//
+// Network access is not restricted by this package, so no rules are
+// needed for querying nameservers, over UDP or TCP.
 func DNS() *Path {
-	return &Path{mode: modeDNS}
+	return Group("dns")
 }
 
 // Certs creates a Path representing the common files needed for SSL/TLS
-// certificate validation.
+// certificate validation, including the file and directories given by the
+// SSL_CERT_FILE and SSL_CERT_DIR environment variables, and the targets of
+// symlinks in those directories, by directory unless the directory is a
+// top-level directory such as /etc. Use GroupPaths("certs") to see what is included.
 func Certs() *Path {
-	return &Path{mode: modeCerts}
+	return Group("certs")
+}
+
+// NSS creates a Path representing the files needed by the C library to look
+// up users, groups, hosts, and the other databases of the Name Service
+// Switch, e.g. by os/user or by the cgo resolver of net. The sources of
+// each database are read from /etc/nsswitch.conf, falling back to the
+// defaults of glibc when the file or an entry is missing, and only what they
+// need is included: the files of the files, compat, and db sources, the
+// libnss_*.so.2 module of every other source along with its shared
+// libraries, and the sockets of daemons such as sssd and systemd-userdbd.
+// The socket of nscd is included if present, and /etc/passwd and /etc/group
+// are always included, as the pure Go os/user reads them directly.
+//
+// The shadow and gshadow databases of password hashes are never included,
+// as looking up users and groups does not need them.
+func NSS() *Path {
+	return Group("nss")
 }
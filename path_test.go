@@ -4,6 +4,7 @@
 package landlock
 
 import (
+	"os"
 	"testing"
 
 	"github.com/shoenig/test/must"
@@ -33,6 +34,22 @@ func TestPath_Dir(t *testing.T) {
 	}
 }
 
+func TestPath_FD(t *testing.T) {
+	a, err := os.Open(".")
+	must.NoError(t, err)
+	defer a.Close()
+
+	b, err := os.Open(".")
+	must.NoError(t, err)
+	defer b.Close()
+
+	pa := FDDir(a, "r")
+	pb := FDDir(b, "r")
+	must.EqOp(t, ".", pa.path)
+	must.True(t, pa.dir)
+	must.NotEq(t, pa.Hash(), pb.Hash()) // same name, different descriptor
+}
+
 func TestPath_ParsePath(t *testing.T) {
 	cases := []struct {
 		input string
@@ -134,3 +151,16 @@ func TestPath_IsProperPath(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePath_group(t *testing.T) {
+	p, err := ParsePath("g::certs")
+	must.NoError(t, err)
+	must.Eq(t, Group("certs"), p)
+	must.Eq(t, "(group:certs)", p.String())
+
+	_, err = ParsePath("g:r:certs")
+	must.ErrorIs(t, err, ErrImproperMode)
+
+	_, err = ParsePath("g:: ")
+	must.ErrorIs(t, err, ErrImproperPath)
+}
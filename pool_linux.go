@@ -0,0 +1,188 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed indicates a Pool was used after being closed
+var ErrPoolClosed = errors.New("pool closed")
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Workers is the number of child processes serving requests. The
+	// default is one.
+	Workers int
+
+	// MaxRequests is the number of requests a child process serves before
+	// it is replaced by a new one. The default of zero means unlimited.
+	MaxRequests int
+}
+
+// A Pool is a set of long-lived child processes, each restricted by the
+// same Locker, serving requests to a registered function.
+//
+// Unlike RunIsolated, the cost of starting and restricting a child process
+// is not paid on every request. A child process which crashes is replaced
+// by a new one on the next request. A Pool is safe for concurrent use.
+type Pool[I, O any] struct {
+	locker Locker
+	name   string
+	config PoolConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// idle workers, where nil is a worker needing to be started
+	idle chan *poolWorker
+
+	lock   sync.RWMutex
+	closed bool
+}
+
+type poolWorker struct {
+	*worker
+	served int
+}
+
+// NewPool starts the child processes of a Pool, each restricted by l and
+// serving the function registered under name.
+//
+// The child processes are re-executions of the current binary, which must
+// call Trampoline.
+func NewPool[I, O any](l Locker, name string, config PoolConfig) (*Pool[I, O], error) {
+	config.Workers = max(1, config.Workers)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool[I, O]{
+		locker: l,
+		name:   name,
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+		idle:   make(chan *poolWorker, config.Workers),
+	}
+
+	for i := range config.Workers {
+		w, err := p.start()
+		if err != nil {
+			for range config.Workers - i {
+				p.idle <- nil
+			}
+			return nil, errors.Join(err, p.Close())
+		}
+		p.idle <- w
+	}
+
+	return p, nil
+}
+
+func (p *Pool[I, O]) start() (*poolWorker, error) {
+	w, err := startWorker(p.ctx, p.locker, p.name)
+	if err != nil {
+		return nil, err
+	}
+	return &poolWorker{worker: w}, nil
+}
+
+// Run sends input to the registered function in one of the child processes
+// of p, returning the output of the function.
+//
+// If ctx is done before the function returns, the child process is killed
+// and replaced, and the error of ctx is returned.
+func (p *Pool[I, O]) Run(ctx context.Context, input I) (O, error) {
+	var output O
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return output, ErrPoolClosed
+	}
+
+	var w *poolWorker
+	select {
+	case <-ctx.Done():
+		return output, ctx.Err()
+	case w = <-p.idle:
+	}
+
+	if w == nil {
+		var err error
+		if w, err = p.start(); err != nil {
+			p.idle <- nil
+			return output, err
+		}
+	}
+
+	type result struct {
+		r   response[O]
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		if res.err = w.enc.Encode(&input); res.err == nil {
+			res.err = w.dec.Decode(&res.r)
+		}
+		done <- res
+	}()
+
+	var res result
+	select {
+	case <-ctx.Done():
+		_ = w.cmd.Process.Kill()
+		<-done
+		_ = w.stop(p.ctx)
+		p.idle <- nil
+		return output, ctx.Err()
+	case res = <-done:
+	}
+
+	if res.err != nil {
+		// the child process crashed or otherwise went away
+		err := w.stop(p.ctx)
+		if err == nil {
+			err = ErrWorkerExited
+		}
+		p.idle <- nil
+		return output, err
+	}
+
+	w.served++
+	if p.config.MaxRequests > 0 && w.served >= p.config.MaxRequests {
+		_ = w.stop(p.ctx)
+		w = nil
+	}
+	p.idle <- w
+
+	if res.r.Err != "" {
+		return res.r.Output, errors.New(res.r.Err)
+	}
+	return res.r.Output, nil
+}
+
+// Close stops the child processes of p, waiting for any requests in
+// progress to complete.
+func (p *Pool[I, O]) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	var errs error
+	for range p.config.Workers {
+		if w := <-p.idle; w != nil {
+			errs = errors.Join(errs, w.stop(p.ctx))
+		}
+	}
+	p.cancel()
+	return errs
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+)
+
+func init() {
+	Register("pid", func(int) (int, error) {
+		return os.Getpid(), nil
+	})
+	Register("exit", func(code int) (int, error) {
+		os.Exit(code)
+		return 0, nil
+	})
+}
+
+func TestPool_Run(t *testing.T) {
+	p, err := NewPool[string, string](New(), "upper", PoolConfig{Workers: 2})
+	must.NoError(t, err)
+	defer func() { must.NoError(t, p.Close()) }()
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	errs := make([]error, 10)
+	for i := range results {
+		wg.Go(func() {
+			results[i], errs[i] = p.Run(t.Context(), "hello")
+		})
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		must.NoError(t, errs[i])
+		must.Eq(t, "HELLO", result)
+	}
+}
+
+func TestPool_restricted(t *testing.T) {
+	l := New(File("tests/fruits/apple.txt", "r"))
+	p, err := NewPool[string, string](l, "read", PoolConfig{})
+	must.NoError(t, err)
+	defer func() { must.NoError(t, p.Close()) }()
+
+	result, err := p.Run(t.Context(), "tests/fruits/apple.txt")
+	must.NoError(t, err)
+	must.Eq(t, "apple\n\n", result)
+
+	_, err = p.Run(t.Context(), "tests/fruits/banana.txt")
+	must.ErrorContains(t, err, "permission denied")
+}
+
+func TestPool_reuse(t *testing.T) {
+	p, err := NewPool[int, int](New(), "pid", PoolConfig{Workers: 1})
+	must.NoError(t, err)
+	defer func() { must.NoError(t, p.Close()) }()
+
+	a, err := p.Run(t.Context(), 0)
+	must.NoError(t, err)
+	b, err := p.Run(t.Context(), 0)
+	must.NoError(t, err)
+	must.Eq(t, a, b)
+	must.NotEq(t, os.Getpid(), a)
+}
+
+func TestPool_maxRequests(t *testing.T) {
+	p, err := NewPool[int, int](New(), "pid", PoolConfig{Workers: 1, MaxRequests: 2})
+	must.NoError(t, err)
+	defer func() { must.NoError(t, p.Close()) }()
+
+	a, err := p.Run(t.Context(), 0)
+	must.NoError(t, err)
+	b, err := p.Run(t.Context(), 0)
+	must.NoError(t, err)
+	c, err := p.Run(t.Context(), 0)
+	must.NoError(t, err)
+	must.Eq(t, a, b)
+	must.NotEq(t, b, c)
+}
+
+func TestPool_crash(t *testing.T) {
+	p, err := NewPool[int, int](New(), "exit", PoolConfig{Workers: 1})
+	must.NoError(t, err)
+	defer func() { must.NoError(t, p.Close()) }()
+
+	_, err = p.Run(t.Context(), 3)
+	must.ErrorIs(t, err, ErrWorkerExited)
+
+	// the crashed worker is replaced
+	_, err = p.Run(t.Context(), 3)
+	must.ErrorIs(t, err, ErrWorkerExited)
+}
+
+func TestPool_cancel(t *testing.T) {
+	p, err := NewPool[time.Duration, int](New(), "sleep", PoolConfig{Workers: 1})
+	must.NoError(t, err)
+	defer func() { must.NoError(t, p.Close()) }()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	_, err = p.Run(ctx, time.Minute)
+	must.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the killed worker is replaced
+	result, err := p.Run(t.Context(), 0)
+	must.NoError(t, err)
+	must.Eq(t, 1, result)
+}
+
+func TestPool_closed(t *testing.T) {
+	p, err := NewPool[string, string](New(), "upper", PoolConfig{})
+	must.NoError(t, err)
+	must.NoError(t, p.Close())
+
+	_, err = p.Run(t.Context(), "hello")
+	must.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func TestPool_lockFailure(t *testing.T) {
+	l := New(File("tests/fruits/missing.txt", "r"))
+	_, err := NewPool[string, string](l, "upper", PoolConfig{Workers: 2})
+	must.ErrorIs(t, err, ErrLandlockFailedToLock)
+}
+
+func TestPool_foreignLocker(t *testing.T) {
+	_, err := NewPool[string, string](foreignLocker{New()}, "upper", PoolConfig{Workers: 2})
+	must.ErrorIs(t, err, ErrForeignLocker)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Program creates a Path representing the program of the given name, as
+// found by searching the directories of PATH, or of the directories set by
+// the SearchPath option.
+//
+// The program is resolved when the Locker is created, following symbolic
+// links (e.g. busybox multi-call links), and allowed along with whatever
+// is needed to execute it, as with Script. A name containing a slash is
+// not searched for. If the program cannot be found, Lock returns an error
+// wrapping exec.ErrNotFound.
+func Program(name string) *Path {
+	if !IsProperPath(name) {
+		panic("improper path")
+	}
+	return &Path{
+		mode: modeDerived,
+		path: name,
+		expand: func(l *locker) ([]*Path, error) {
+			program, err := l.lookPath(name)
+			if err != nil {
+				return nil, err
+			}
+			return script(program, 0)
+		},
+	}
+}
+
+// SearchPath creates a Path which configures the Locker to find each
+// Program in the directories of path, a list separated by colons, rather
+// than in the directories of PATH.
+func SearchPath(path string) *Path {
+	return &Path{
+		mode: modeOption,
+		opt: func(l *locker) {
+			l.search = &path
+		},
+	}
+}
+
+// lookPath returns the absolute path of the executable file name, found in
+// the search path of l. Relative directories of the search path are
+// ignored, so that the result does not depend on the working directory.
+func (l *locker) lookPath(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return absolute(l.base, name), nil
+	}
+
+	search := os.Getenv("PATH")
+	if l.search != nil {
+		search = *l.search
+	}
+
+	for _, dir := range filepath.SplitList(search) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		candidate := filepath.Join(dir, name)
+		if executableFile(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// executableFile returns whether path is a regular file with an execute
+// permission bit set, following symbolic links.
+func executableFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
@@ -0,0 +1,105 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestLocker_lookPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tool"), "#!/bin/sh\n", 0o755)
+	writeFile(t, filepath.Join(dir, "data"), "not executable", 0o644)
+	must.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+
+	cases := []struct {
+		name   string
+		search string
+		exp    string
+		ok     bool
+	}{
+		{name: "tool", search: "/nonexistent:" + dir, exp: filepath.Join(dir, "tool"), ok: true},
+		{name: "data", search: dir},
+		{name: "sub", search: dir},
+		{name: "tool", search: "relative:."},
+		{name: "/usr/bin/cat", search: "", exp: "/usr/bin/cat", ok: true},
+	}
+
+	for _, tc := range cases {
+		l := New(SearchPath(tc.search)).(*locker)
+		result, err := l.lookPath(tc.name)
+		if !tc.ok {
+			must.ErrorIs(t, err, exec.ErrNotFound)
+			continue
+		}
+		must.NoError(t, err)
+		must.Eq(t, tc.exp, result)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	base := func(l Locker) []string {
+		var result []string
+		for _, p := range l.(*locker).paths.Slice() {
+			result = append(result, filepath.Base(p.path))
+		}
+		return result
+	}
+
+	t.Run("path", func(t *testing.T) {
+		t.Setenv("PATH", "/usr/local/bin:/usr/bin:/bin")
+		l := New(Program("cat"))
+		must.NoError(t, l.Check(Mandatory))
+		must.SliceContains(t, base(l), "cat")
+		must.SliceContains(t, base(l), "libc.so.6")
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		must.NoError(t, os.Symlink("/usr/bin/cat", filepath.Join(dir, "kitty")))
+		l := New(SearchPath(dir), Program("kitty"))
+		must.NoError(t, l.Check(Mandatory))
+		must.SliceContains(t, base(l), "cat")
+		must.SliceNotContains(t, base(l), "kitty")
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		l := New(SearchPath(t.TempDir()), Program("cat"))
+		err := l.Check(Mandatory)
+		must.ErrorIs(t, err, exec.ErrNotFound)
+	})
+}
+
+func TestLocker_Program(t *testing.T) {
+	cases := map[string]func(){
+		"exec": func() {
+			err := New(
+				Program("cat"),
+				File("/dev/null", "rw"), // stdin of exec.Cmd
+				File("tests/fruits/apple.txt", "r"),
+			).Lock(Mandatory)
+			must.NoError(t, err)
+
+			cmd := exec.CommandContext(t.Context(), "cat", "tests/fruits/apple.txt")
+			b, err := cmd.Output()
+			must.NoError(t, err)
+			must.Eq(t, "apple\n\n", string(b))
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_Program", cases)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+package landlock
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MaxLayers is the maximum number of landlock layers which can be applied
+// to a process by the kernel. Each Lock of a different Locker adds a layer.
+const MaxLayers = 16
+
+var (
+	registryLock sync.RWMutex
+	registry     []layer
+)
+
+// layer is a ruleset applied to the process.
+type layer struct {
+	paths []*Path // nil if unknown
+}
+
+// record adds a layer restricting the process to paths, which is nil if
+// the paths are unknown.
+func record(paths []*Path) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry = append(registry, layer{paths: paths})
+}
+
+// Layers returns the number of landlock layers applied to the process by
+// this package. The kernel allows at most MaxLayers.
+//
+// Layers applied by other means, including those inherited from a parent
+// process, are not known.
+func Layers() int {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return len(registry)
+}
+
+// Restricted returns whether the process has been restricted by this
+// package, by any of Lock, Jail, Stages, or RestrictFromFD.
+func Restricted() bool {
+	return Layers() > 0
+}
+
+// Effective returns the paths and permissions allowed by the intersection
+// of every layer applied to the process, or nil if the process has not been
+// restricted.
+//
+// Layers applied by RestrictFromFD are counted by Layers, but their paths
+// are not known and not reflected by Effective.
+func Effective() []*Path {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return effective(registry)
+}
+
+func effective(layers []layer) []*Path {
+	var result []*Path
+	known := false
+	for _, l := range layers {
+		switch {
+		case l.paths == nil:
+			continue
+		case !known:
+			result = slices.Clone(l.paths)
+			known = true
+		default:
+			result = intersect(result, l.paths)
+		}
+	}
+	if !known {
+		return nil
+	}
+	slices.SortFunc(result, func(a, b *Path) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return result
+}
+
+// intersect returns the paths allowed by both a and b.
+func intersect(a, b []*Path) []*Path {
+	merged := make(map[string]*Path)
+	for _, p := range a {
+		for _, q := range b {
+			var deep *Path
+			switch {
+			case covers(p, q):
+				deep = q
+			case covers(q, p):
+				deep = p
+			default:
+				continue
+			}
+			mode := intersectMode(p.mode, q.mode)
+			if mode == "" {
+				continue
+			}
+			if m, exists := merged[deep.path]; exists {
+				m.mode = unionMode(m.mode, mode)
+				continue
+			}
+			merged[deep.path] = &Path{mode: mode, path: deep.path, dir: deep.dir}
+		}
+	}
+	result := make([]*Path, 0, len(merged))
+	for _, p := range merged {
+		result = append(result, p)
+	}
+	return result
+}
+
+const modes = "rwcx"
+
+func intersectMode(a, b string) string {
+	var sb strings.Builder
+	for _, c := range modes {
+		if strings.ContainsRune(a, c) && strings.ContainsRune(b, c) {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func unionMode(a, b string) string {
+	var sb strings.Builder
+	for _, c := range modes {
+		if strings.ContainsRune(a, c) || strings.ContainsRune(b, c) {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
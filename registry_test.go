@@ -0,0 +1,90 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+package landlock
+
+import (
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestRegistry_effective(t *testing.T) {
+	cases := []struct {
+		name   string
+		layers []layer
+		exp    []*Path
+	}{
+		{
+			name:   "none",
+			layers: nil,
+			exp:    nil,
+		},
+		{
+			name: "one",
+			layers: []layer{
+				{paths: []*Path{Dir("/srv", "rw"), File("/etc/hosts", "r")}},
+			},
+			exp: []*Path{File("/etc/hosts", "r"), Dir("/srv", "rw")},
+		},
+		{
+			name: "empty",
+			layers: []layer{
+				{paths: []*Path{Dir("/srv", "rw")}},
+				{paths: []*Path{}},
+			},
+			exp: []*Path{},
+		},
+		{
+			name: "nested",
+			layers: []layer{
+				{paths: []*Path{Dir("/srv", "rwc"), Dir("/etc", "r")}},
+				{paths: []*Path{Dir("/srv/data", "rx"), File("/etc/hosts", "rw")}},
+			},
+			exp: []*Path{File("/etc/hosts", "r"), Dir("/srv/data", "r")},
+		},
+		{
+			name: "disjoint",
+			layers: []layer{
+				{paths: []*Path{Dir("/srv/app", "rw")}},
+				{paths: []*Path{Dir("/srv/application", "rw")}},
+			},
+			exp: []*Path{},
+		},
+		{
+			name: "merged",
+			layers: []layer{
+				{paths: []*Path{Dir("/srv", "r"), Dir("/srv/data", "w")}},
+				{paths: []*Path{Dir("/srv/data", "rw")}},
+			},
+			exp: []*Path{Dir("/srv/data", "rw")},
+		},
+		{
+			name: "unknown",
+			layers: []layer{
+				{paths: nil},
+				{paths: []*Path{Dir("/srv", "r")}},
+			},
+			exp: []*Path{Dir("/srv", "r")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := effective(tc.layers)
+			must.Eq(t, tc.exp, result)
+		})
+	}
+}
+
+func TestRegistry_unrestricted(t *testing.T) {
+	must.Zero(t, Layers())
+	must.False(t, Restricted())
+	must.Nil(t, Effective())
+}
+
+func Test_unionMode(t *testing.T) {
+	must.Eq(t, "r", unionMode("r", "r"))
+	must.Eq(t, "rx", unionMode("x", "r"))
+	must.Eq(t, "rwcx", unionMode("xc", "wr"))
+}
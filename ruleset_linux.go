@@ -0,0 +1,81 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoThreadSync indicates a ruleset which may be shared with other
+// processes cannot be applied to every thread without modifying it, as
+// the thread synchronising flag of landlock is not available to a process
+// using cgo
+var ErrNoThreadSync = errors.New("shared ruleset requires landlock thread sync")
+
+// Ruleset creates the landlock ruleset of l without restricting the
+// process, returning the ruleset as an open file.
+//
+// Every path of l is opened and added to the ruleset now, which allows a
+// parent process that can see all of the paths to prepare the ruleset for
+// a child process. The file can then be passed to the child process using
+// exec.Cmd.ExtraFiles, and the child process can restrict itself using
+// RestrictFromFD, without needing to open anything.
+//
+// Children not written in Go can do the same with the prctl(2) and
+// landlock_restrict_self(2) system calls.
+//
+// If l was not created by New, ErrForeignLocker is returned.
+func Ruleset(l Locker) (*os.File, error) {
+	if !available {
+		return nil, ErrLandlockNotAvailable
+	}
+	lk, ok := l.(*locker)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrForeignLocker, l)
+	}
+	f, err := lk.ruleset()
+	if err != nil {
+		return nil, errors.Join(ErrLandlockFailedToLock, err)
+	}
+	return f, nil
+}
+
+// RestrictFromFD restricts the process using the landlock ruleset of the
+// open file descriptor fd, as created by Ruleset.
+//
+// As with Lock, no_new_privs is set and the ruleset is applied on all
+// threads of the process. The file descriptor is not closed, and the
+// ruleset is not modified, so it may be shared with other processes.
+//
+// A process using cgo must be given access to its own /proc/<pid>/task
+// by psx, which is only possible by modifying the ruleset. Such processes
+// therefore need ThreadSync, otherwise ErrNoThreadSync is returned.
+//
+// The layer is counted by Layers, but as the paths of the ruleset are not
+// known they are not reflected by Effective.
+func RestrictFromFD(fd int) error {
+	if !available {
+		return ErrLandlockNotAvailable
+	}
+	// the ruleset may be shared with other children, so it must not be
+	// given any rules of this process
+	if err := enforceShared(fd); err != nil {
+		return errors.Join(ErrLandlockFailedToLock, err)
+	}
+	record(nil)
+	return nil
+}
+
+// ruleset creates the landlock ruleset of l as an open file.
+func (l *locker) ruleset() (*os.File, error) {
+	fd, err := l.build()
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "landlock-ruleset"), nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestRuleset_error(t *testing.T) {
+	_, err := Ruleset(New(File("tests/fruits/missing.txt", "r")))
+	must.ErrorIs(t, err, ErrLandlockFailedToLock)
+	must.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRuleset_foreignLocker(t *testing.T) {
+	_, err := Ruleset(foreignLocker{New()})
+	must.ErrorIs(t, err, ErrForeignLocker)
+}
+
+func TestRestrictFromFD(t *testing.T) {
+	// in the child process, restrict using the inherited ruleset
+	if os.Getenv("TEST_RULESET") != "" {
+		err := RestrictFromFD(3)
+		if psxReadsTasks && !ThreadSync() {
+			must.ErrorIs(t, err, ErrNoThreadSync)
+			return
+		}
+		must.NoError(t, err)
+
+		_, err = os.ReadFile("tests/fruits/apple.txt")
+		must.NoError(t, err)
+		_, err = os.ReadFile("tests/fruits/banana.txt")
+		must.ErrorIs(t, err, os.ErrPermission)
+
+		// the ruleset shared with other children gained no rules
+		_, err = os.ReadDir(fmt.Sprintf("/proc/%d/task", os.Getpid()))
+		must.ErrorIs(t, err, os.ErrPermission)
+		return
+	}
+
+	// in the parent process, prepare the ruleset for the child
+	rs, err := Ruleset(New(File("tests/fruits/apple.txt", "r")))
+	must.NoError(t, err)
+	defer func() { _ = rs.Close() }()
+
+	cmd := exec.CommandContext(t.Context(), os.Args[0], "-test.run=TestRestrictFromFD")
+	cmd.Env = append(os.Environ(), "TEST_RULESET=1")
+	cmd.ExtraFiles = []*os.File{rs}
+	b, err := cmd.CombinedOutput()
+	t.Logf("TEST[ruleset]\n\t|> %s\n\n", string(b))
+	must.NoError(t, err)
+
+	// the parent process is not restricted
+	_, err = os.ReadFile("tests/fruits/banana.txt")
+	must.NoError(t, err)
+}
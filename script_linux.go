@@ -0,0 +1,157 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInterpreterDepth indicates a script whose chain of interpreters is
+// deeper than the kernel allows
+var ErrInterpreterDepth = errors.New("too many levels of script interpreters")
+
+const (
+	// maxInterpreters is the number of nested interpreters allowed by the
+	// kernel when executing a script
+	maxInterpreters = 4
+
+	// shebangSize is the number of bytes of a script read by the kernel to
+	// find its interpreter
+	shebangSize = 256
+)
+
+// Script creates a Path representing the script at path, along with its
+// interpreter and the dynamic loader and shared libraries of the
+// interpreter, as with Executable. The script is allowed "rx" so that it
+// can be executed directly.
+//
+// The interpreter is read from the "#!" line of the script. If the
+// interpreter is itself a script, its interpreter is included too. For
+// scripts using env, such as "#!/usr/bin/env python3", the program run by
+// env is located using the PATH of the current process. If path is not a
+// script, Script is the same as Executable.
+//
+// As with Executable, any error resolving the interpreter is returned by
+// Lock.
+func Script(path string) *Path {
+	if !IsProperPath(path) {
+		panic("improper path")
+	}
+	return &Path{
+		mode: modeDerived,
+		path: path,
+		expand: func(l *locker) ([]*Path, error) {
+			return script(absolute(l.base, path), 0)
+		},
+	}
+}
+
+// script resolves the paths needed to execute the script at path, where
+// depth is the number of interpreters already followed.
+func script(path string, depth int) ([]*Path, error) {
+	interp, arg, ok, err := shebang(path)
+	switch {
+	case err != nil:
+		return nil, err
+	case !ok:
+		return executable(path)
+	case depth >= maxInterpreters:
+		return nil, fmt.Errorf("%w: %s", ErrInterpreterDepth, path)
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []*Path{File(real, "rx")}
+
+	more, err := script(absolute("", interp), depth+1)
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, more...)
+
+	if filepath.Base(interp) == "env" {
+		if name, ok := envProgram(strings.Fields(arg)); ok {
+			program, err := exec.LookPath(name)
+			if err != nil {
+				return nil, err
+			}
+			if more, err = script(absolute("", program), depth+1); err != nil {
+				return nil, err
+			}
+			paths = append(paths, more...)
+		}
+	}
+
+	return paths, nil
+}
+
+// shebang returns the interpreter and optional argument named by the "#!"
+// line of the file at path, in the manner of the kernel.
+func shebang(path string) (string, string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	b := make([]byte, shebangSize)
+	n, err := io.ReadFull(f, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", false, err
+	}
+	b = b[:n]
+
+	line, ok := bytes.CutPrefix(b, []byte("#!"))
+	if !ok {
+		return "", "", false, nil
+	}
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+
+	s := strings.Trim(string(line), " \t")
+	interp, arg := s, ""
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		interp, arg = s[:i], s[i+1:]
+	}
+	if interp == "" {
+		return "", "", false, fmt.Errorf("%w: no interpreter: %s", ErrNotExecutable, path)
+	}
+	return interp, strings.Trim(arg, " \t"), true, nil
+}
+
+// envProgram returns the program run by env given args, skipping options
+// and environment variable assignments.
+func envProgram(args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", false
+		case arg == "-u" || arg == "-C" || arg == "--unset" || arg == "--chdir":
+			i++ // skip the value of the option
+		case strings.HasPrefix(arg, "-"):
+			continue
+		case strings.Contains(arg, "="):
+			continue
+		default:
+			return arg, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,159 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func Test_shebang(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []struct {
+		name    string
+		content string
+		interp  string
+		arg     string
+		ok      bool
+	}{
+		{name: "none", content: "echo hi\n"},
+		{name: "plain", content: "#!/bin/sh\necho hi\n", interp: "/bin/sh", ok: true},
+		{name: "space", content: "#! /bin/sh -e \n", interp: "/bin/sh", arg: "-e", ok: true},
+		{name: "tab", content: "#!/usr/bin/env\tpython3 -u\n", interp: "/usr/bin/env", arg: "python3 -u", ok: true},
+		{name: "no_newline", content: "#!/bin/bash", interp: "/bin/bash", ok: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			writeFile(t, path, tc.content, 0o755)
+			interp, arg, ok, err := shebang(path)
+			must.NoError(t, err)
+			must.Eq(t, tc.ok, ok)
+			must.Eq(t, tc.interp, interp)
+			must.Eq(t, tc.arg, arg)
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		path := filepath.Join(dir, "empty")
+		writeFile(t, path, "#!  \n", 0o755)
+		_, _, _, err := shebang(path)
+		must.ErrorIs(t, err, ErrNotExecutable)
+	})
+}
+
+func Test_envProgram(t *testing.T) {
+	cases := []struct {
+		args []string
+		exp  string
+		ok   bool
+	}{
+		{args: []string{"python3"}, exp: "python3", ok: true},
+		{args: []string{"-S", "python3", "-u"}, exp: "python3", ok: true},
+		{args: []string{"-i", "A=1", "B=2", "bash"}, exp: "bash", ok: true},
+		{args: []string{"-u", "HOME", "-C", "/tmp", "sh"}, exp: "sh", ok: true},
+		{args: []string{"--", "-weird"}, exp: "-weird", ok: true},
+		{args: []string{"-i"}, ok: false},
+		{args: nil, ok: false},
+	}
+
+	for _, tc := range cases {
+		name, ok := envProgram(tc.args)
+		must.Eq(t, tc.ok, ok)
+		must.Eq(t, tc.exp, name)
+	}
+}
+
+func TestScript(t *testing.T) {
+	names := func(paths []*Path) []string {
+		var result []string
+		for _, p := range paths {
+			result = append(result, filepath.Base(p.path))
+		}
+		return result
+	}
+
+	t.Run("env", func(t *testing.T) {
+		paths, err := script(absolute("", "tests/fruits/hello.sh"), 0)
+		must.NoError(t, err)
+		result := names(paths)
+		must.Eq(t, "hello.sh", result[0])
+		must.SliceContains(t, result, "env")
+		must.SliceContains(t, result, "bash")
+		must.SliceContains(t, result, "libc.so.6")
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		dir := t.TempDir()
+		inner := filepath.Join(dir, "inner")
+		outer := filepath.Join(dir, "outer")
+		writeFile(t, inner, "#!/bin/sh\nexec cat \"$@\"\n", 0o755)
+		writeFile(t, outer, "#!"+inner+"\n", 0o755)
+		paths, err := script(outer, 0)
+		must.NoError(t, err)
+		result := names(paths)
+		must.Eq(t, []string{"outer", "inner"}, result[:2])
+		sh, err := filepath.EvalSymlinks("/bin/sh")
+		must.NoError(t, err)
+		must.SliceContains(t, result, filepath.Base(sh))
+	})
+
+	t.Run("loop", func(t *testing.T) {
+		dir := t.TempDir()
+		loop := filepath.Join(dir, "loop")
+		writeFile(t, loop, "#!"+loop+"\n", 0o755)
+		_, err := script(loop, 0)
+		must.ErrorIs(t, err, ErrInterpreterDepth)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "missing")
+		writeFile(t, path, "#!/usr/bin/env no-such-interpreter-exists\n", 0o755)
+		_, err := script(path, 0)
+		must.ErrorIs(t, err, exec.ErrNotFound)
+	})
+
+	t.Run("elf", func(t *testing.T) {
+		paths, err := script("/usr/bin/cat", 0)
+		must.NoError(t, err)
+		must.Eq(t, "cat", filepath.Base(paths[0].path))
+	})
+}
+
+func TestLocker_Script(t *testing.T) {
+	cases := map[string]func(){
+		"exec": func() {
+			err := New(
+				Script("tests/fruits/hello.sh"),
+				File("/dev/null", "rw"), // stdin of exec.Cmd
+			).Lock(Mandatory)
+			must.NoError(t, err)
+
+			cmd := exec.CommandContext(t.Context(), "tests/fruits/hello.sh")
+			b, err := cmd.CombinedOutput()
+			must.NoError(t, err)
+			must.Eq(t, "so you like fruit?", string(b))
+
+			_, err = os.ReadFile("tests/fruits/apple.txt")
+			must.ErrorIs(t, err, os.ErrPermission)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestLocker_Script", cases)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrStageNotFound indicates a stage name that was not declared
+	ErrStageNotFound = errors.New("stage not found")
+
+	// ErrStageDuplicate indicates a stage name declared more than once
+	ErrStageDuplicate = errors.New("duplicate stage name")
+
+	// ErrStageOrder indicates entering a stage declared before the
+	// current stage
+	ErrStageOrder = errors.New("stage already passed")
+
+	// ErrStageNotSubset indicates a stage allowing access not allowed
+	// by an earlier stage
+	ErrStageNotSubset = errors.New("stage not a subset of earlier stage")
+
+	// ErrTooManyLayers indicates more landlock layers than supported
+	// by the kernel, see MaxLayers
+	ErrTooManyLayers = errors.New("too many landlock layers")
+)
+
+// A Stage is a named Locker, used to restrict a process during one phase
+// of its lifetime.
+type Stage struct {
+	Name   string
+	Locker Locker
+}
+
+// Stages is an ordered sequence of Stage, each allowing a subset of the
+// access allowed by the stage before it.
+//
+// A typical service declares an "init" stage with broad access needed for
+// startup, followed by a "serve" stage with only the access needed to serve
+// requests. Entering a stage locks the process with the Locker of that
+// stage, adding one landlock layer, which must not exceed MaxLayers in
+// total. Stages may be skipped, but cannot be entered out of order.
+type Stages struct {
+	lock    sync.Mutex
+	stages  []Stage
+	lockers []*locker // of each stage
+	current int       // index of the current stage, or -1
+}
+
+// NewStages creates Stages from the given stages, in order.
+//
+// Each stage is validated to be a subset of the stage before it, such that
+// every path of a stage is covered by the paths of the previous stage, with
+// the same or fewer permissions. Stage names must be unique, and each
+// Locker must have been created by New.
+func NewStages(stages ...Stage) (*Stages, error) {
+	if len(stages) > MaxLayers {
+		return nil, fmt.Errorf("%w: %d stages", ErrTooManyLayers, len(stages))
+	}
+
+	lockers := make([]*locker, len(stages))
+	for i, stage := range stages {
+		lk, ok := stage.Locker.(*locker)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: %T", ErrForeignLocker, stage.Name, stage.Locker)
+		}
+		lockers[i] = lk
+		for _, previous := range stages[:i] {
+			if previous.Name == stage.Name {
+				return nil, fmt.Errorf("%w: %q", ErrStageDuplicate, stage.Name)
+			}
+		}
+	}
+
+	for i := 1; i < len(stages); i++ {
+		if err := subset(lockers[i], lockers[i-1]); err != nil {
+			return nil, fmt.Errorf("%w: %q of %q: %w",
+				ErrStageNotSubset, stages[i].Name, stages[i-1].Name, err)
+		}
+	}
+	return &Stages{
+		stages:  stages,
+		lockers: lockers,
+		current: -1,
+	}, nil
+}
+
+// Enter locks the process using the Locker of the named stage.
+//
+// Entering the current stage again does nothing. Entering a stage declared
+// before the current stage returns ErrStageOrder. If the process is not
+// locked because of safety (e.g. Try where landlock is not available), the
+// current stage is left unchanged.
+func (s *Stages) Enter(name string, safety Safety) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	i := s.index(name)
+	switch {
+	case i < 0:
+		return fmt.Errorf("%w: %q", ErrStageNotFound, name)
+	case i == s.current:
+		return nil
+	case i < s.current:
+		return fmt.Errorf("%w: %q", ErrStageOrder, name)
+	case Layers() >= MaxLayers:
+		return fmt.Errorf("%w: %d applied", ErrTooManyLayers, Layers())
+	}
+
+	l := s.lockers[i]
+	if err := l.Lock(safety); err != nil {
+		return err
+	}
+	if l.isLocked() {
+		s.current = i
+	}
+	return nil
+}
+
+// Current returns the name of the current stage, or the empty string if no
+// stage has been entered.
+func (s *Stages) Current() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current < 0 {
+		return ""
+	}
+	return s.stages[s.current].Name
+}
+
+func (s *Stages) index(name string) int {
+	for i, stage := range s.stages {
+		if stage.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// subset returns an error describing the first path of next which is not
+// covered by the paths of previous.
+func subset(next, previous *locker) error {
+	for _, q := range next.paths.Slice() {
+		allow := rule(0)
+		for _, p := range previous.paths.Slice() {
+			if covers(p, q) {
+				allow |= p.access()
+			}
+		}
+		if excess := q.access() &^ allow; excess != 0 {
+			return fmt.Errorf("%s:%s", q.mode, q.path)
+		}
+	}
+	return nil
+}
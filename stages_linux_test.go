@@ -0,0 +1,162 @@
+// Copyright (c) Seth Hoenig
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestNewStages(t *testing.T) {
+	t.Run("subset", func(t *testing.T) {
+		_, err := NewStages(
+			Stage{Name: "init", Locker: New(Dir("/etc", "r"), Dir("/srv", "rwc"))},
+			Stage{Name: "serve", Locker: New(File("/etc/hosts", "r"), Dir("/srv/data", "rw"))},
+			Stage{Name: "drain", Locker: New(Dir("/srv/data", "r"))},
+		)
+		must.NoError(t, err)
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		// access may be covered by more than one earlier path
+		_, err := NewStages(
+			Stage{Name: "init", Locker: New(Dir("/srv", "r"), Dir("/srv/data", "w"))},
+			Stage{Name: "serve", Locker: New(Dir("/srv/data", "rw"))},
+		)
+		must.NoError(t, err)
+	})
+
+	t.Run("more_mode", func(t *testing.T) {
+		_, err := NewStages(
+			Stage{Name: "init", Locker: New(Dir("/srv", "r"))},
+			Stage{Name: "serve", Locker: New(Dir("/srv/data", "rw"))},
+		)
+		must.ErrorIs(t, err, ErrStageNotSubset)
+		must.ErrorContains(t, err, "rw:/srv/data")
+	})
+
+	t.Run("outside", func(t *testing.T) {
+		_, err := NewStages(
+			Stage{Name: "init", Locker: New(Dir("/srv/app", "r"))},
+			Stage{Name: "serve", Locker: New(Dir("/srv/application", "r"))},
+		)
+		must.ErrorIs(t, err, ErrStageNotSubset)
+	})
+
+	t.Run("file_parent", func(t *testing.T) {
+		_, err := NewStages(
+			Stage{Name: "init", Locker: New(File("/srv/app", "r"))},
+			Stage{Name: "serve", Locker: New(File("/srv/app/config", "r"))},
+		)
+		must.ErrorIs(t, err, ErrStageNotSubset)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := NewStages(
+			Stage{Name: "init", Locker: New(Dir("/srv", "r"))},
+			Stage{Name: "init", Locker: New(Dir("/srv/data", "r"))},
+		)
+		must.ErrorIs(t, err, ErrStageDuplicate)
+	})
+
+	t.Run("foreign", func(t *testing.T) {
+		_, err := NewStages(
+			Stage{Name: "init", Locker: New(Dir("/srv", "r"))},
+			Stage{Name: "serve", Locker: foreignLocker{New()}},
+		)
+		must.ErrorIs(t, err, ErrForeignLocker)
+	})
+
+	t.Run("too_many", func(t *testing.T) {
+		stages := make([]Stage, MaxLayers+1)
+		for i := range stages {
+			stages[i] = Stage{Name: string(rune('a' + i)), Locker: New()}
+		}
+		_, err := NewStages(stages...)
+		must.ErrorIs(t, err, ErrTooManyLayers)
+	})
+}
+
+func TestStages_Enter(t *testing.T) {
+	newStages := func() *Stages {
+		s, err := NewStages(
+			Stage{Name: "init", Locker: New(Dir("tests", "r"))},
+			Stage{Name: "serve", Locker: New(Dir("tests/fruits", "r"))},
+			Stage{Name: "drain", Locker: New(File("tests/fruits/apple.txt", "r"))},
+		)
+		must.NoError(t, err)
+		return s
+	}
+
+	cases := map[string]func(){
+		"in_order": func() {
+			s := newStages()
+			must.Eq(t, "", s.Current())
+
+			err := s.Enter("init", Mandatory)
+			must.NoError(t, err)
+			must.Eq(t, "init", s.Current())
+			_, err = os.ReadFile("tests/veggies/corn.txt")
+			must.NoError(t, err)
+
+			err = s.Enter("serve", Mandatory)
+			must.NoError(t, err)
+			must.Eq(t, "serve", s.Current())
+			_, err = os.ReadFile("tests/veggies/corn.txt")
+			must.ErrorIs(t, err, os.ErrPermission)
+			_, err = os.ReadFile("tests/fruits/banana.txt")
+			must.NoError(t, err)
+		},
+		"skip": func() {
+			s := newStages()
+			err := s.Enter("drain", Mandatory)
+			must.NoError(t, err)
+			_, err = os.ReadFile("tests/fruits/banana.txt")
+			must.ErrorIs(t, err, os.ErrPermission)
+		},
+		"out_of_order": func() {
+			s := newStages()
+			err := s.Enter("serve", Mandatory)
+			must.NoError(t, err)
+			err = s.Enter("serve", Mandatory)
+			must.NoError(t, err)
+			err = s.Enter("init", Mandatory)
+			must.ErrorIs(t, err, ErrStageOrder)
+			must.Eq(t, "serve", s.Current())
+		},
+		"try_failed": func() {
+			s, err := NewStages(
+				Stage{Name: "init", Locker: New(Dir("tests", "r"))},
+				Stage{Name: "serve", Locker: New(File("tests/fruits/missing.txt", "r"))},
+			)
+			must.NoError(t, err)
+			err = s.Enter("init", Mandatory)
+			must.NoError(t, err)
+
+			// nothing was locked, so the stage is not entered
+			err = s.Enter("serve", Try)
+			must.NoError(t, err)
+			must.Eq(t, "init", s.Current())
+			_, err = os.ReadFile("tests/veggies/corn.txt")
+			must.NoError(t, err)
+		},
+		"not_found": func() {
+			s := newStages()
+			err := s.Enter("missing", Mandatory)
+			must.ErrorIs(t, err, ErrStageNotFound)
+		},
+	}
+
+	// if we are child process, run the assigned test case
+	if isChildRunner(cases) {
+		return
+	}
+
+	// otherwise if we are parent process, launch child processes
+	forkAndRunEachCase(t, "TestStages_Enter", cases)
+}
@@ -109,6 +109,26 @@ func add(fd int, ba *beneathAttr) error {
 	return errno(e1)
 }
 
+// open path as an O_PATH descriptor without resolving symbolic links or
+// magic links (e.g. /proc/self/fd/N) in any component of path
+func openat2(path string) (int, error) {
+	how := unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_NO_SYMLINKS | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	return unix.Openat2(unix.AT_FDCWD, path, &how)
+}
+
+// identify the file referred to by fd, and whether it is a directory
+func identify(fd int) (inode, bool, error) {
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return inode{}, false, err
+	}
+	id := inode{dev: uint64(st.Dev), ino: st.Ino}
+	return id, st.Mode&unix.S_IFMT == unix.S_IFDIR, nil
+}
+
 // https://git.kernel.org/pub/scm/libs/libcap/libcap.git/tree/psx/psx.go
 //
 // apply NO_NEW_PRIVS to all OS threads concurrently (with or without CGO)
@@ -133,6 +153,39 @@ func restrict(fd int) error {
 	return errno(e1)
 }
 
+// apply SYS_LANDLOCK_RESTRICT_SELF to all OS threads atomically, using the
+// thread synchronising flag of the kernel rather than psx; the call waits
+// for every other thread, so the runtime must know it may block
+func restrictSync(fd int) error {
+	_, _, e1 := syscall.Syscall(
+		unix.SYS_LANDLOCK_RESTRICT_SELF,
+		uintptr(fd),
+		unix.LANDLOCK_RESTRICT_SELF_TSYNC,
+		0,
+	)
+	return errno(e1)
+}
+
+// apply NO_NEW_PRIVS to the current OS thread only
+func prctlThread() error {
+	_, _, e1 := syscall.RawSyscall6(
+		syscall.SYS_PRCTL,
+		unix.PR_SET_NO_NEW_PRIVS,
+		1, 0, 0, 0, 0,
+	)
+	return errno(e1)
+}
+
+// apply SYS_LANDLOCK_RESTRICT_SELF to the current OS thread only
+func restrictThread(fd int) error {
+	_, _, e1 := syscall.RawSyscall(
+		unix.SYS_LANDLOCK_RESTRICT_SELF,
+		uintptr(fd),
+		0, 0,
+	)
+	return errno(e1)
+}
+
 func errno(e syscall.Errno) error {
 	if e == 0 {
 		return nil